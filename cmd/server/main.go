@@ -3,16 +3,25 @@ package main
 import (
 	"context"
 	"log/slog"
+	"math"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
+	_ "time/tzdata" // часовые пояса расписаний и в образах без системной tzdata
 
 	"github.com/ArthurGuatsaev/smarthome/internal/app"
 	"github.com/ArthurGuatsaev/smarthome/internal/config"
 	"github.com/ArthurGuatsaev/smarthome/internal/httpapi"
+	"github.com/ArthurGuatsaev/smarthome/internal/ingest"
+	"github.com/ArthurGuatsaev/smarthome/internal/metrics"
+	"github.com/ArthurGuatsaev/smarthome/internal/mqtt"
+	"github.com/ArthurGuatsaev/smarthome/internal/rules"
+	"github.com/ArthurGuatsaev/smarthome/internal/schedule"
 	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+	"github.com/ArthurGuatsaev/smarthome/internal/webhook"
 )
 
 func main() {
@@ -34,8 +43,63 @@ func main() {
 		os.Exit(1)
 	}
 
-	application := app.New(db)
-	srv := httpapi.NewServer(application, cfg.APIKey)
+	// дом по умолчанию для устройств без homeId; MQTT_HOME_ID учитывается только при его создании
+	if err := storage.NewHomeRepo(db.DB).EnsureDefault(migCtx, cfg.MQTTHomeID, time.Now().UTC()); err != nil {
+		slog.Error("db_default_home_error", "err", err)
+		os.Exit(1)
+	}
+
+	storage.SetQueryObserver(metrics.ObserveDBQuery)
+	metrics.RegisterDB(db.DB)
+
+	mq := mqtt.New(mqtt.Config{
+		BrokerURL: cfg.MQTTBrokerURL,
+		ClientID:  cfg.MQTTClientID,
+		Username:  cfg.MQTTUsername,
+		Password:  cfg.MQTTPassword,
+	})
+	defer mq.Close()
+	metrics.RegisterMQTT(mq.IsConnected)
+
+	if cfg.APIKey == "" {
+		slog.Warn("api_key_not_set", "hint", "only keys stored in db are accepted; set API_KEY to bootstrap an admin key")
+	}
+
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		slog.Error("timezone_error", "timezone", cfg.Timezone, "err", err)
+		os.Exit(1)
+	}
+	planner := schedule.Planner{Location: loc}
+	if !math.IsNaN(cfg.Latitude) && !math.IsNaN(cfg.Longitude) {
+		planner.Coordinates = &schedule.Coordinates{Latitude: cfg.Latitude, Longitude: cfg.Longitude}
+	}
+
+	application := app.New(db, mq, cfg.APIKey)
+	// устройства со старым форматом capabilities (имена действий) — на возможности реестра
+	if err := application.MigrateLegacyCapabilities(migCtx); err != nil {
+		slog.Error("device_capabilities_migrate_error", "err", err)
+		os.Exit(1)
+	}
+	// отложенные шаги сцен живут только в памяти процесса
+	if err := application.InterruptSceneRuns(migCtx); err != nil {
+		slog.Error("scene_runs_interrupt_error", "err", err)
+		os.Exit(1)
+	}
+	ingest.New(application).Register(mq)
+
+	srv := httpapi.NewServer(application, httpapi.Config{
+		KeyRateLimit:    httpapi.RateLimit{RPS: cfg.RateLimitKeyRPS, Burst: cfg.RateLimitKeyBurst},
+		DeviceRateLimit: httpapi.RateLimit{RPS: cfg.RateLimitDeviceRPS, Burst: cfg.RateLimitDeviceBurst},
+		Planner:         planner,
+		IdempotencyTTL:  cfg.IdempotencyTTL,
+	})
+	srv.AddReadyCheck("db", db.PingContext)
+	srv.AddReadyCheck("migrations", func(ctx context.Context) error {
+		return storage.CheckVersion(ctx, db.DB)
+	})
+	srv.AddReadyCheck("mqtt", mq.Check)
+
 	httpServer := &http.Server{
 		Addr:         cfg.HTTPAddr,
 		Handler:      srv.Handler(),
@@ -47,6 +111,33 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	var workers sync.WaitGroup
+	workers.Go(func() {
+		application.RunCommandTimeouts(ctx, cfg.CommandSweepInterval, cfg.CommandTimeout)
+	})
+	workers.Go(func() {
+		application.RunPresenceSweep(ctx, cfg.PresenceSweepInterval, cfg.PresenceTimeout)
+	})
+	workers.Go(func() {
+		application.RunHistoryPruning(ctx, cfg.HistoryPruneInterval, cfg.HistoryRetention)
+	})
+	workers.Go(func() {
+		application.RunIdempotencyPruning(ctx, time.Hour)
+	})
+	workers.Go(func() {
+		rules.New(application).Run(ctx)
+	})
+	workers.Go(func() {
+		schedule.New(application, planner).Run(ctx)
+	})
+	workers.Go(func() {
+		webhook.New(application, webhook.Config{
+			Timeout:      cfg.WebhookTimeout,
+			MaxAttempts:  cfg.WebhookMaxAttempts,
+			LogRetention: cfg.WebhookLogRetention,
+		}).Run(ctx)
+	})
+
 	go func() {
 		slog.Info("server_start", "addr", cfg.HTTPAddr)
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -58,6 +149,7 @@ func main() {
 	<-ctx.Done()
 
 	slog.Info("server_shutdown")
+	srv.SetReady(false)
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
@@ -66,6 +158,9 @@ func main() {
 	} else {
 		slog.Info("shutdown_ok")
 	}
+
+	// фоновые воркеры останавливаются по ctx
+	workers.Wait()
 }
 
 func setupLogger(level string) {
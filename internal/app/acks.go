@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+var (
+	ErrInvalidAck        = errors.New("invalid ack payload")
+	ErrCommandNotFound   = errors.New("command not found")
+	ErrCommandNotPending = errors.New("command is not pending")
+	ErrAckDeviceMismatch = errors.New("ack from another device")
+)
+
+// ackMessage — формат ack в топике home/{homeId}/device/{id}/ack
+type ackMessage struct {
+	CommandID string `json:"commandId"`
+	OK        *bool  `json:"ok"`
+	Error     string `json:"error"`
+}
+
+// HandleAck обновляет статус команды по ответу устройства (acked/failed).
+func (a *App) HandleAck(ctx context.Context, mqttHomeID, mqttDeviceID string, payload []byte) error {
+	var m ackMessage
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAck, err)
+	}
+	if m.CommandID == "" || m.OK == nil {
+		return fmt.Errorf("%w: commandId and ok required", ErrInvalidAck)
+	}
+
+	d, err := a.Devices.GetByTopic(ctx, mqttHomeID, mqttDeviceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrDeviceNotFound
+		}
+		return err
+	}
+	// даже отвергнутый ack — признак того, что устройство на связи
+	if err := a.markSeen(ctx, d.ID); err != nil {
+		return err
+	}
+
+	c, err := a.Commands.Get(ctx, m.CommandID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCommandNotFound
+		}
+		return err
+	}
+	if c.DeviceID != d.ID {
+		return ErrAckDeviceMismatch
+	}
+
+	at := time.Now().UTC()
+	updated, err := a.Commands.SetAck(ctx, c.ID, *m.OK, m.Error, at)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrCommandNotPending
+	}
+
+	c.Status = storage.CommandAcked
+	if !*m.OK {
+		c.Status = storage.CommandFailed
+	}
+	c.Error = m.Error
+	c.AckedAt = &at
+	a.publishCommandEvent(EventCommandAck, c)
+	return nil
+}
+
+// RunCommandTimeouts раз в interval переводит в timeout pending-команды старше timeout.
+// Блокируется до отмены ctx.
+func (a *App) RunCommandTimeouts(ctx context.Context, interval, timeout time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := a.sweepCommandTimeouts(ctx, timeout); err != nil && ctx.Err() == nil {
+				slog.Error("command_timeout_sweep_error", "err", err)
+			}
+		}
+	}
+}
+
+func (a *App) sweepCommandTimeouts(ctx context.Context, timeout time.Duration) error {
+	pending, err := a.Commands.ListPendingBefore(ctx, time.Now().Add(-timeout))
+	if err != nil {
+		return err
+	}
+
+	for _, c := range pending {
+		updated, err := a.Commands.SetTimeout(ctx, c.ID)
+		if err != nil {
+			return err
+		}
+		if updated {
+			slog.Info("command_timeout", "command_id", c.ID, "device_id", c.DeviceID)
+			c.Status = storage.CommandTimeout
+			a.publishCommandEvent(EventCommandTimeout, c)
+		}
+	}
+	return nil
+}
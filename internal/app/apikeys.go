@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+// Права API-ключей. admin включает все остальные.
+const (
+	ScopeDevicesRead  = "devices:read"
+	ScopeCommandsSend = "commands:send"
+	ScopeAdmin        = "admin"
+)
+
+var AllScopes = []string{ScopeDevicesRead, ScopeCommandsSend, ScopeAdmin}
+
+var (
+	ErrInvalidAPIKey   = errors.New("invalid api key")
+	ErrInvalidScopes   = errors.New("invalid scopes")
+	ErrReservedKeyName = errors.New("api key name is reserved")
+)
+
+// Principal — кто выполняет запрос (владелец API-ключа).
+type Principal struct {
+	KeyID  string
+	Name   string
+	Scopes []string
+}
+
+func (p Principal) Has(scope string) bool {
+	return slices.Contains(p.Scopes, ScopeAdmin) || slices.Contains(p.Scopes, scope)
+}
+
+// Ключ из env API_KEY: админ для первичной настройки, в БД не хранится.
+// Имя зарезервировано: по нему ведутся аудит и лимиты bootstrap-ключа.
+const bootstrapKeyName = "bootstrap"
+
+// MintAPIKey создаёт ключ; открытое значение возвращается только здесь.
+func (a *App) MintAPIKey(ctx context.Context, name string, scopes []string) (storage.APIKey, string, error) {
+	if strings.EqualFold(name, bootstrapKeyName) {
+		return storage.APIKey{}, "", fmt.Errorf("%w: %q", ErrReservedKeyName, name)
+	}
+	if len(scopes) == 0 {
+		return storage.APIKey{}, "", fmt.Errorf("%w: at least one scope required", ErrInvalidScopes)
+	}
+	for _, sc := range scopes {
+		if !slices.Contains(AllScopes, sc) {
+			return storage.APIKey{}, "", fmt.Errorf("%w: unknown scope %q", ErrInvalidScopes, sc)
+		}
+	}
+
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	plain := "shk_" + hex.EncodeToString(b)
+
+	scopesJSON, _ := json.Marshal(scopes)
+	k := storage.APIKey{
+		ID:         newID(),
+		Name:       name,
+		KeyHash:    hashAPIKey(plain),
+		ScopesJSON: string(scopesJSON),
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := a.APIKeys.Create(ctx, k); err != nil {
+		return storage.APIKey{}, "", err
+	}
+	return k, plain, nil
+}
+
+// Authenticate проверяет ключ: сначала bootstrap-ключ из конфига, затем ключи в БД.
+// Сравнение хэшей — за постоянное время.
+func (a *App) Authenticate(ctx context.Context, key string) (Principal, error) {
+	if key == "" {
+		return Principal{}, ErrInvalidAPIKey
+	}
+	hash := hashAPIKey(key)
+
+	if a.bootstrapKeyHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(a.bootstrapKeyHash)) == 1 {
+		return Principal{Name: bootstrapKeyName, Scopes: []string{ScopeAdmin}}, nil
+	}
+
+	k, err := a.APIKeys.GetActiveByHash(ctx, hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Principal{}, ErrInvalidAPIKey
+		}
+		return Principal{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(k.KeyHash)) != 1 {
+		return Principal{}, ErrInvalidAPIKey
+	}
+
+	var scopes []string
+	_ = json.Unmarshal([]byte(k.ScopesJSON), &scopes)
+	return Principal{KeyID: k.ID, Name: k.Name, Scopes: scopes}, nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
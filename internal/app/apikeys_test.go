@@ -0,0 +1,33 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/testutil"
+)
+
+func TestMintAPIKeyReservedName(t *testing.T) {
+	ctx := context.Background()
+	a := testutil.NewApp(t, nil)
+
+	for _, name := range []string{"bootstrap", "Bootstrap"} {
+		if _, _, err := a.MintAPIKey(ctx, name, []string{app.ScopeDevicesRead}); !errors.Is(err, app.ErrReservedKeyName) {
+			t.Fatalf("MintAPIKey(%q): err = %v, want %v", name, err, app.ErrReservedKeyName)
+		}
+	}
+
+	k, plain, err := a.MintAPIKey(ctx, "tablet", []string{app.ScopeDevicesRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := a.Authenticate(ctx, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "tablet" || p.KeyID != k.ID {
+		t.Fatalf("principal = %+v", p)
+	}
+}
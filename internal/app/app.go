@@ -1,19 +1,81 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/capability"
 	"github.com/ArthurGuatsaev/smarthome/internal/storage"
 )
 
+var ErrDeviceNotFound = errors.New("device not found")
+
+// Publisher отправляет сообщения устройствам (MQTT).
+type Publisher interface {
+	Publish(ctx context.Context, topic string, qos byte, payload []byte) error
+}
+
 type App struct {
-	Devices  *storage.DeviceRepo
-	States   *storage.StateRepo
-	Commands *storage.CommandRepo
+	Homes     *storage.HomeRepo
+	Rooms     *storage.RoomRepo
+	Devices   *storage.DeviceRepo
+	States    *storage.StateRepo
+	History   *storage.HistoryRepo
+	Commands  *storage.CommandRepo
+	Rules     *storage.RuleRepo
+	Groups    *storage.GroupRepo
+	Scenes    *storage.SceneRepo
+	Schedules *storage.ScheduleRepo
+	Webhooks  *storage.WebhookRepo
+	APIKeys   *storage.APIKeyRepo
+	Audit     *storage.AuditRepo
+	Events    *Bus
+
+	GroupCommands *storage.GroupCommandRepo
+	SceneRuns     *storage.SceneRunRepo
+	Deliveries    *storage.WebhookDeliveryRepo
+	Idempotency   *storage.IdempotencyRepo
+	Capabilities  *capability.Registry
+
+	pub Publisher
+
+	scenesMu     sync.Mutex
+	activeScenes map[string]activeScene // sceneID -> запуск с отложенными шагами
+
+	bootstrapKeyHash string
 }
 
-func New(db *storage.DB) *App {
-	return &App{
-		Devices:  storage.NewDeviceRepo(db.DB),
-		States:   storage.NewStateRepo(db.DB),
-		Commands: storage.NewCommandRepo(db.DB),
+// New собирает приложение. bootstrapKey — админский ключ из env (может быть пустым).
+func New(db *storage.DB, pub Publisher, bootstrapKey string) *App {
+	a := &App{
+		Homes:     storage.NewHomeRepo(db.DB),
+		Rooms:     storage.NewRoomRepo(db.DB),
+		Devices:   storage.NewDeviceRepo(db.DB),
+		States:    storage.NewStateRepo(db.DB),
+		History:   storage.NewHistoryRepo(db.DB),
+		Commands:  storage.NewCommandRepo(db.DB),
+		Rules:     storage.NewRuleRepo(db.DB),
+		Groups:    storage.NewGroupRepo(db.DB),
+		Scenes:    storage.NewSceneRepo(db.DB),
+		Schedules: storage.NewScheduleRepo(db.DB),
+		Webhooks:  storage.NewWebhookRepo(db.DB),
+		APIKeys:   storage.NewAPIKeyRepo(db.DB),
+		Audit:     storage.NewAuditRepo(db.DB),
+		Events:    NewBus(),
+
+		GroupCommands: storage.NewGroupCommandRepo(db.DB),
+		SceneRuns:     storage.NewSceneRunRepo(db.DB),
+		Deliveries:    storage.NewWebhookDeliveryRepo(db.DB),
+		Idempotency:   storage.NewIdempotencyRepo(db.DB),
+		Capabilities:  capability.Builtin(),
+
+		pub: pub,
+
+		activeScenes: map[string]activeScene{},
+	}
+	if bootstrapKey != "" {
+		a.bootstrapKeyHash = hashAPIKey(bootstrapKey)
 	}
+	return a
 }
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/mqtt"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+var (
+	ErrUnsupportedAction = errors.New("action not supported by device")
+	ErrInvalidParams     = errors.New("invalid command params")
+	ErrPublish           = errors.New("command publish failed")
+	ErrRateLimited       = errors.New("device command rate limit exceeded")
+)
+
+// DeviceLimit тратит токен лимита команд устройства; при отказе — ошибка с ErrRateLimited.
+// Нужен для fan-out (группы, шаги сцен), где один запрос порождает команды многим устройствам.
+type DeviceLimit func(deviceID string) error
+
+// Таймаут на публикацию команды в брокер
+const publishTimeout = 3 * time.Second
+
+// Источники команд (commands.source); правила пишут "rule:<id>"
+const (
+	SourceAPI = "api"
+	SourceWS  = "ws"
+)
+
+type CommandRequest struct {
+	ID       string // по умолчанию новый; задаётся, если ID нужен до отправки
+	DeviceID string
+	Action   string
+	Params   json.RawMessage
+
+	Source         string // по умолчанию SourceAPI
+	CorrelationID  string // по умолчанию — ID самой команды
+	GroupCommandID string // заполняет CreateGroupCommand
+
+	DeviceLimit DeviceLimit // nil — без лимита на устройство
+}
+
+// commandMessage — формат команды в топике home/{homeId}/device/{id}/command
+type commandMessage struct {
+	CommandID string          `json:"commandId"`
+	Action    string          `json:"action"`
+	Params    json.RawMessage `json:"params"`
+	TS        string          `json:"ts"`
+}
+
+// CheckCommand проверяет, что команду можно отправить: устройство есть,
+// действие и params допустимы по его capabilities (схемы из реестра capability).
+// Возвращает устройство и нормализованные params.
+func (a *App) CheckCommand(ctx context.Context, deviceID, action string, raw json.RawMessage) (storage.Device, string, error) {
+	d, err := a.Devices.Get(ctx, deviceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Device{}, "", ErrDeviceNotFound
+		}
+		return storage.Device{}, "", err
+	}
+
+	var caps []string
+	_ = json.Unmarshal([]byte(d.Capabilities), &caps)
+	schema, ok := a.Capabilities.ResolveAction(caps, action)
+	if !ok {
+		return storage.Device{}, "", fmt.Errorf("%w: %s", ErrUnsupportedAction, action)
+	}
+
+	params, err := normalizeParams(raw)
+	if err != nil {
+		return storage.Device{}, "", err
+	}
+	if schema != nil {
+		if err := schema.Validate([]byte(params)); err != nil {
+			return storage.Device{}, "", fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+	}
+	return d, params, nil
+}
+
+// CreateCommand проверяет команду (CheckCommand), сохраняет её
+// в статусе pending и публикует в MQTT. Если публикация не удалась, команда
+// помечается failed и возвращается вместе с ErrPublish.
+func (a *App) CreateCommand(ctx context.Context, req CommandRequest) (storage.Command, error) {
+	d, params, err := a.CheckCommand(ctx, req.DeviceID, req.Action, req.Params)
+	if err != nil {
+		return storage.Command{}, err
+	}
+	if req.DeviceLimit != nil {
+		if err := req.DeviceLimit(d.ID); err != nil {
+			return storage.Command{}, err
+		}
+	}
+
+	home, err := a.Homes.Get(ctx, d.HomeID)
+	if err != nil {
+		return storage.Command{}, fmt.Errorf("device home %s: %w", d.HomeID, err)
+	}
+
+	c := storage.Command{
+		ID:             req.ID,
+		DeviceID:       d.ID,
+		Action:         req.Action,
+		ParamsJSON:     params,
+		Status:         storage.CommandPending,
+		CreatedAt:      time.Now().UTC(),
+		Source:         req.Source,
+		CorrelationID:  req.CorrelationID,
+		GroupCommandID: req.GroupCommandID,
+	}
+	if c.ID == "" {
+		c.ID = newID()
+	}
+	if c.Source == "" {
+		c.Source = SourceAPI
+	}
+	if c.CorrelationID == "" {
+		c.CorrelationID = c.ID
+	}
+	if err := a.Commands.Create(ctx, c); err != nil {
+		return storage.Command{}, err
+	}
+	a.publishCommandEvent(EventCommandCreated, c)
+
+	msg, _ := json.Marshal(commandMessage{
+		CommandID: c.ID,
+		Action:    c.Action,
+		Params:    json.RawMessage(c.ParamsJSON),
+		TS:        c.CreatedAt.Format(time.RFC3339Nano),
+	})
+
+	pubCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	if err := a.pub.Publish(pubCtx, mqtt.DeviceTopic(home.MQTTHomeID, d.MQTTDeviceID, mqtt.KindCommand), 1, msg); err != nil {
+		if ferr := a.Commands.SetFailed(ctx, c.ID, "publish: "+err.Error()); ferr != nil {
+			return c, ferr
+		}
+		c.Status = storage.CommandFailed
+		c.Error = "publish: " + err.Error()
+		// для подписчиков это такой же финальный результат, как failed-ack
+		a.publishCommandEvent(EventCommandAck, c)
+		return c, fmt.Errorf("%w: %v", ErrPublish, err)
+	}
+
+	return c, nil
+}
+
+func (a *App) publishCommandEvent(t EventType, c storage.Command) {
+	a.Events.Publish(Event{
+		Type:      t,
+		DeviceID:  c.DeviceID,
+		CommandID: c.ID,
+		Data:      CommandData{Action: c.Action, Status: c.Status, Error: c.Error, Source: c.Source},
+	})
+}
+
+func normalizeParams(raw json.RawMessage) (string, error) {
+	if len(bytes.TrimSpace(raw)) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return "{}", nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return "", fmt.Errorf("%w: must be a json object", ErrInvalidParams)
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return "", fmt.Errorf("%w: must be a json object", ErrInvalidParams)
+	}
+	return buf.String(), nil
+}
+
+// NewCorrelationID — идентификатор новой цепочки команд.
+func NewCorrelationID() string { return newID() }
+
+// NewCommandID — ID для CommandRequest.ID.
+func NewCommandID() string { return newID() }
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,181 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+// ErrInvalidCapabilities — тип устройства или capabilities не проходят проверку реестра.
+var ErrInvalidCapabilities = errors.New("invalid device capabilities")
+
+// DevicePatch — частичное обновление устройства; nil-поля не меняются.
+// RoomID "" снимает устройство с комнаты; смена дома без RoomID тоже снимает.
+type DevicePatch struct {
+	Name         *string
+	Type         *string
+	Capabilities *[]string
+	MQTTDeviceID *string
+	HomeID       *string
+	RoomID       *string
+}
+
+// CreateDevice сохраняет устройство; пустой HomeID — дом по умолчанию,
+// пустые capabilities — набор по умолчанию для типа.
+func (a *App) CreateDevice(ctx context.Context, d storage.Device) (storage.Device, error) {
+	if d.HomeID == "" {
+		d.HomeID = storage.DefaultHomeID
+	}
+	caps, err := a.checkCapabilities(d.Type, d.Capabilities)
+	if err != nil {
+		return storage.Device{}, err
+	}
+	d.Capabilities = caps
+	if err := a.checkPlacement(ctx, d.HomeID, d.RoomID); err != nil {
+		return storage.Device{}, err
+	}
+	if err := a.Devices.Create(ctx, d); err != nil {
+		return storage.Device{}, err
+	}
+	a.Events.Publish(Event{
+		Type:     EventDeviceCreated,
+		DeviceID: d.ID,
+		Data:     deviceData(d),
+	})
+	return d, nil
+}
+
+// DeleteDevice удаляет устройство; событие публикуется, только если оно существовало.
+func (a *App) DeleteDevice(ctx context.Context, id string) error {
+	deleted, err := a.Devices.Delete(ctx, id)
+	if err != nil || !deleted {
+		return err
+	}
+	a.Events.Publish(Event{Type: EventDeviceDeleted, DeviceID: id})
+	return nil
+}
+
+// UpdateDevice применяет patch. Занятый mqttDeviceId — storage.ErrConflict.
+func (a *App) UpdateDevice(ctx context.Context, id string, p DevicePatch) (storage.Device, error) {
+	d, err := a.Devices.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Device{}, ErrDeviceNotFound
+		}
+		return storage.Device{}, err
+	}
+
+	if p.Name != nil {
+		d.Name = *p.Name
+	}
+	if p.Type != nil {
+		d.Type = *p.Type
+	}
+	if p.MQTTDeviceID != nil {
+		d.MQTTDeviceID = *p.MQTTDeviceID
+	}
+	if p.Capabilities != nil {
+		caps, _ := json.Marshal(*p.Capabilities)
+		d.Capabilities = string(caps)
+	}
+	if p.Type != nil || p.Capabilities != nil {
+		caps, err := a.checkCapabilities(d.Type, d.Capabilities)
+		if err != nil {
+			return storage.Device{}, err
+		}
+		d.Capabilities = caps
+	}
+	if p.HomeID != nil && *p.HomeID != d.HomeID {
+		d.HomeID = *p.HomeID
+		d.RoomID = ""
+	}
+	if p.RoomID != nil {
+		d.RoomID = *p.RoomID
+	}
+	if p.HomeID != nil || p.RoomID != nil {
+		if err := a.checkPlacement(ctx, d.HomeID, d.RoomID); err != nil {
+			return storage.Device{}, err
+		}
+	}
+
+	updated, err := a.Devices.Update(ctx, d)
+	if err != nil {
+		return storage.Device{}, err
+	}
+	if !updated {
+		return storage.Device{}, ErrDeviceNotFound
+	}
+
+	a.Events.Publish(Event{
+		Type:     EventDeviceUpdated,
+		DeviceID: d.ID,
+		Data:     deviceData(d),
+	})
+	return d, nil
+}
+
+// checkCapabilities проверяет capabilities (JSON-массив) по реестру и
+// возвращает итоговый JSON (с набором по умолчанию, если список пуст).
+// Устаревшие имена действий заменяются возможностями (см. capability.Normalize).
+func (a *App) checkCapabilities(deviceType, capsJSON string) (string, error) {
+	var caps []string
+	if capsJSON != "" {
+		if err := json.Unmarshal([]byte(capsJSON), &caps); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidCapabilities, err)
+		}
+	}
+	caps, _ = a.Capabilities.Normalize(caps)
+	caps, err := a.Capabilities.CheckDevice(deviceType, caps)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCapabilities, err)
+	}
+	out, _ := json.Marshal(caps)
+	return string(out), nil
+}
+
+// MigrateLegacyCapabilities переводит сохранённые устройства со старым форматом
+// capabilities (имена действий) на возможности реестра, чтобы их команды
+// проверялись по схемам. Имена, которых нет в реестре, остаются и логируются.
+func (a *App) MigrateLegacyCapabilities(ctx context.Context) error {
+	q := storage.DeviceListQuery{Sort: storage.DeviceSortCreatedAsc, Limit: 500}
+	for {
+		page, err := a.Devices.List(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, d := range page.Items {
+			var caps []string
+			if err := json.Unmarshal([]byte(d.Capabilities), &caps); err != nil {
+				slog.Warn("device_capabilities_invalid", "device_id", d.ID, "err", err)
+				continue
+			}
+			norm, replaced := a.Capabilities.Normalize(caps)
+			for _, c := range norm {
+				if _, ok := a.Capabilities.Lookup(c); !ok {
+					slog.Warn("device_capability_unknown", "device_id", d.ID, "capability", c)
+				}
+			}
+			if len(replaced) == 0 {
+				continue
+			}
+			out, _ := json.Marshal(norm)
+			if err := a.Devices.SetCapabilities(ctx, d.ID, string(out)); err != nil {
+				return err
+			}
+			slog.Info("device_capabilities_migrated", "device_id", d.ID, "from", d.Capabilities, "to", string(out))
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+func deviceData(d storage.Device) DeviceData {
+	return DeviceData{Name: d.Name, Type: d.Type, MQTTDeviceID: d.MQTTDeviceID, HomeID: d.HomeID, RoomID: d.RoomID}
+}
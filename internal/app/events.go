@@ -0,0 +1,209 @@
+package app
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventDeviceStateChanged EventType = "device.state_changed"
+	EventDeviceCreated      EventType = "device.created"
+	EventDeviceUpdated      EventType = "device.updated"
+	EventDeviceDeleted      EventType = "device.deleted"
+	EventDeviceOnline       EventType = "device.online"
+	EventDeviceOffline      EventType = "device.offline"
+	EventCommandCreated     EventType = "command.created"
+	EventCommandAck         EventType = "command.ack"
+	EventCommandTimeout     EventType = "command.timeout"
+)
+
+// EventTypes — все типы событий шины (фильтры подписок проверяются по нему).
+var EventTypes = []EventType{
+	EventDeviceStateChanged, EventDeviceCreated, EventDeviceUpdated, EventDeviceDeleted,
+	EventDeviceOnline, EventDeviceOffline,
+	EventCommandCreated, EventCommandAck, EventCommandTimeout,
+}
+
+// Event — внутреннее событие. Data зависит от Type:
+// StateChangedData, DeviceData, PresenceData или CommandData.
+// У device.state_changed CommandID заполнен, если устройство сообщило,
+// что состояние изменилось по команде (поле "commandId" в телеметрии).
+type Event struct {
+	ID        uint64    `json:"id"` // монотонный номер, присваивает шина
+	Type      EventType `json:"type"`
+	DeviceID  string    `json:"deviceId,omitempty"`
+	CommandID string    `json:"commandId,omitempty"`
+	At        time.Time `json:"at"`
+	Data      any       `json:"data,omitempty"`
+}
+
+type StateChangedData struct {
+	State json.RawMessage `json:"state"`
+}
+
+type DeviceData struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	MQTTDeviceID string `json:"mqttDeviceId"`
+	HomeID       string `json:"homeId"`
+	RoomID       string `json:"roomId,omitempty"`
+}
+
+type PresenceData struct {
+	Online     bool       `json:"online"`
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty"`
+	Reason     string     `json:"reason"` // message | status | silence
+}
+
+type CommandData struct {
+	Action string `json:"action"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Source string `json:"source"`
+}
+
+const (
+	// Размер буфера подписчика по умолчанию
+	DefaultSubscriberBuffer = 64
+	// Сколько последних событий шина хранит для resume (SSE Last-Event-ID)
+	replaySize = 512
+)
+
+// Bus — in-process pub/sub.
+//
+// Политика backpressure: Publish никогда не блокируется. У каждого подписчика
+// свой ограниченный буфер; если он переполнен, событие для этого подписчика
+// отбрасывается и увеличивается его счётчик Dropped. Остальные подписчики
+// получают событие как обычно. Подписчик, которому важна полнота (например,
+// SSE с resume), должен сам заметить пропуск по разрыву в Event.ID.
+type Bus struct {
+	mu     sync.Mutex
+	nextID uint64
+	subs   map[*Subscription]struct{}
+
+	replay []Event // кольцевой буфер последних событий
+	head   int     // куда писать следующее событие
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subs:   map[*Subscription]struct{}{},
+		replay: make([]Event, 0, replaySize),
+	}
+}
+
+type Subscription struct {
+	bus     *Bus
+	ch      chan Event
+	filter  func(Event) bool
+	dropped atomic.Uint64
+	closed  bool // под bus.mu
+}
+
+// Subscribe создаёт подписку с буфером buf. filter может быть nil (все события).
+func (b *Bus) Subscribe(buf int, filter func(Event) bool) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.subscribeLocked(buf, filter)
+}
+
+// SubscribeSince атомарно подписывается и возвращает сохранённые события с ID > lastID,
+// прошедшие filter, — без пропусков и дублей между replay и живым потоком.
+// complete == false, если часть событий после lastID уже вытеснена из буфера.
+func (b *Bus) SubscribeSince(lastID uint64, buf int, filter func(Event) bool) (s *Subscription, missed []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// lastID из будущего — сервер перезапускался, счётчик начался заново
+	complete = lastID <= b.nextID
+	if lastID < b.nextID {
+		ordered := b.replayOrdered()
+		if len(ordered) == 0 || ordered[0].ID > lastID+1 {
+			complete = false
+		}
+		for _, e := range ordered {
+			if e.ID > lastID && (filter == nil || filter(e)) {
+				missed = append(missed, e)
+			}
+		}
+	}
+	return b.subscribeLocked(buf, filter), missed, complete
+}
+
+func (b *Bus) subscribeLocked(buf int, filter func(Event) bool) *Subscription {
+	if buf <= 0 {
+		buf = DefaultSubscriberBuffer
+	}
+	s := &Subscription{bus: b, ch: make(chan Event, buf), filter: filter}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// replayOrdered возвращает содержимое кольцевого буфера от старых к новым.
+func (b *Bus) replayOrdered() []Event {
+	if len(b.replay) < replaySize {
+		return b.replay
+	}
+	out := make([]Event, 0, replaySize)
+	out = append(out, b.replay[b.head:]...)
+	return append(out, b.replay[:b.head]...)
+}
+
+// Publish присваивает событию ID и время и раздаёт его подписчикам.
+func (b *Bus) Publish(e Event) Event {
+	if e.At.IsZero() {
+		e.At = time.Now().UTC()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e.ID = b.nextID
+
+	if len(b.replay) < replaySize {
+		b.replay = append(b.replay, e)
+	} else {
+		b.replay[b.head] = e
+		b.head = (b.head + 1) % replaySize
+	}
+
+	for s := range b.subs {
+		if s.filter != nil && !s.filter(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			// в лог пишем только первый пропуск подписчика, дальше — счётчик
+			if s.dropped.Add(1) == 1 {
+				slog.Warn("event_dropped", "type", e.Type, "id", e.ID)
+			}
+		}
+	}
+	return e
+}
+
+// Events — канал событий; закрывается после Close.
+func (s *Subscription) Events() <-chan Event { return s.ch }
+
+// Dropped — сколько событий отброшено из-за переполненного буфера.
+func (s *Subscription) Dropped() uint64 { return s.dropped.Load() }
+
+func (s *Subscription) Close() {
+	b := s.bus
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(b.subs, s)
+	close(s.ch)
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+var (
+	ErrGroupNotFound        = errors.New("group not found")
+	ErrGroupEmpty           = errors.New("group has no devices")
+	ErrGroupCommandNotFound = errors.New("group command not found")
+)
+
+// Статус участника без созданной команды (действие не поддерживается и т.п.)
+const MemberRejected = "rejected"
+
+// Сводный статус набора команд (групповая команда, запуск сцены)
+const (
+	OutcomePending = "pending" // есть pending-команды
+	OutcomeAcked   = "acked"   // все подтвердили
+	OutcomeFailed  = "failed"  // ни одна не подтверждена
+	OutcomePartial = "partial" // часть acked, часть failed/timeout/rejected
+)
+
+type GroupMemberStatus struct {
+	DeviceID  string
+	CommandID string // пусто для rejected
+	Status    string // статус команды или MemberRejected
+	Error     string
+}
+
+type GroupCommandStatus struct {
+	storage.GroupCommand
+	Status  string
+	Counts  map[string]int // по статусам участников
+	Members []GroupMemberStatus
+}
+
+type rejectedMember struct {
+	DeviceID string `json:"deviceId"`
+	Error    string `json:"error"`
+}
+
+// CreateGroupCommand создаёт по команде на каждого участника группы. Участники,
+// которым команду создать нельзя (нет действия, неверные params, исчерпан
+// req.DeviceLimit), попадают в rejected; ошибка публикации в MQTT — обычный
+// failed у команды участника. При неожиданной ошибке (БД) обход прерывается:
+// групповая команда остаётся с уже созданными командами, в rejected сохраняются
+// отклонённые до этого участники и участник с ошибкой, остальные не попадают никуда.
+func (a *App) CreateGroupCommand(ctx context.Context, groupID string, req CommandRequest) (GroupCommandStatus, error) {
+	g, err := a.Groups.Get(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return GroupCommandStatus{}, ErrGroupNotFound
+		}
+		return GroupCommandStatus{}, err
+	}
+	if len(g.DeviceIDs) == 0 {
+		return GroupCommandStatus{}, ErrGroupEmpty
+	}
+	params, err := normalizeParams(req.Params)
+	if err != nil {
+		return GroupCommandStatus{}, err
+	}
+
+	gc := storage.GroupCommand{
+		ID:         newID(),
+		GroupID:    g.ID,
+		Action:     req.Action,
+		ParamsJSON: params,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := a.GroupCommands.Create(ctx, gc); err != nil {
+		return GroupCommandStatus{}, err
+	}
+
+	if req.Source == "" {
+		req.Source = SourceAPI
+	}
+	rejected := []rejectedMember{}
+	for _, deviceID := range g.DeviceIDs {
+		_, err := a.CreateCommand(ctx, CommandRequest{
+			DeviceID:       deviceID,
+			Action:         req.Action,
+			Params:         json.RawMessage(params),
+			Source:         req.Source,
+			CorrelationID:  gc.ID,
+			GroupCommandID: gc.ID,
+			DeviceLimit:    req.DeviceLimit,
+		})
+		switch {
+		case err == nil, errors.Is(err, ErrPublish):
+		case errors.Is(err, ErrDeviceNotFound), errors.Is(err, ErrUnsupportedAction), errors.Is(err, ErrInvalidParams),
+			errors.Is(err, ErrRateLimited):
+			rejected = append(rejected, rejectedMember{DeviceID: deviceID, Error: err.Error()})
+		default:
+			rejected = append(rejected, rejectedMember{DeviceID: deviceID, Error: err.Error()})
+			if serr := a.setRejected(ctx, &gc, rejected); serr != nil {
+				return GroupCommandStatus{}, errors.Join(err, serr)
+			}
+			return GroupCommandStatus{}, err
+		}
+	}
+
+	if err := a.setRejected(ctx, &gc, rejected); err != nil {
+		return GroupCommandStatus{}, err
+	}
+	return a.groupCommandStatus(ctx, gc)
+}
+
+func (a *App) setRejected(ctx context.Context, gc *storage.GroupCommand, rejected []rejectedMember) error {
+	rj, _ := json.Marshal(rejected)
+	gc.RejectedJSON = string(rj)
+	return a.GroupCommands.SetRejected(ctx, gc.ID, gc.RejectedJSON)
+}
+
+// GroupCommandStatus собирает текущий статус групповой команды из commands.
+func (a *App) GroupCommandStatus(ctx context.Context, id string) (GroupCommandStatus, error) {
+	gc, err := a.GroupCommands.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return GroupCommandStatus{}, ErrGroupCommandNotFound
+		}
+		return GroupCommandStatus{}, err
+	}
+	return a.groupCommandStatus(ctx, gc)
+}
+
+func (a *App) groupCommandStatus(ctx context.Context, gc storage.GroupCommand) (GroupCommandStatus, error) {
+	cmds, err := a.Commands.ListByGroupCommand(ctx, gc.ID)
+	if err != nil {
+		return GroupCommandStatus{}, err
+	}
+	var rejected []rejectedMember
+	_ = json.Unmarshal([]byte(gc.RejectedJSON), &rejected)
+
+	st := GroupCommandStatus{GroupCommand: gc, Counts: map[string]int{}}
+	for _, c := range cmds {
+		st.Members = append(st.Members, GroupMemberStatus{DeviceID: c.DeviceID, CommandID: c.ID, Status: c.Status, Error: c.Error})
+		st.Counts[c.Status]++
+	}
+	for _, r := range rejected {
+		st.Members = append(st.Members, GroupMemberStatus{DeviceID: r.DeviceID, Status: MemberRejected, Error: r.Error})
+		st.Counts[MemberRejected]++
+	}
+
+	st.Status = outcome(st.Counts, len(st.Members), st.Counts[storage.CommandPending])
+	return st, nil
+}
+
+// outcome сводит счётчики статусов к одному OutcomeXxx; pending — сколько ещё не завершено.
+func outcome(counts map[string]int, total, pending int) string {
+	switch {
+	case pending > 0:
+		return OutcomePending
+	case counts[storage.CommandAcked] == total:
+		return OutcomeAcked
+	case counts[storage.CommandAcked] == 0:
+		return OutcomeFailed
+	default:
+		return OutcomePartial
+	}
+}
@@ -0,0 +1,69 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+	"github.com/ArthurGuatsaev/smarthome/internal/testutil"
+)
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(context.Context, string, byte, []byte) error { return nil }
+
+// Неожиданная ошибка посреди обхода группы не теряет уже отклонённых участников.
+func TestGroupCommandSavesRejectedOnError(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewDB(t)
+	a := app.New(db, nopPublisher{}, testutil.AdminKey)
+
+	now := time.Now().UTC()
+	// b не умеет turn_on, вставка команды для c падает, до d обход не доходит
+	for _, id := range []string{"a", "b", "c", "d"} {
+		d := storage.Device{ID: id, Name: id, Type: "switch", MQTTDeviceID: id, Capabilities: `["on_off"]`, CreatedAt: now}
+		if id == "b" {
+			d.Type, d.Capabilities = "sensor", `["motion_sensor"]`
+		}
+		if _, err := a.CreateDevice(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := storage.Group{ID: "g", Name: "g", DeviceIDs: []string{"a", "b", "c", "d"}, CreatedAt: now, UpdatedAt: now}
+	if err := a.Groups.Create(ctx, g); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.ExecContext(ctx, `
+		CREATE TRIGGER fail_c BEFORE INSERT ON commands WHEN NEW.device_id = 'c'
+		BEGIN SELECT RAISE(ABORT, 'boom'); END
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.CreateGroupCommand(ctx, g.ID, app.CommandRequest{Action: "turn_on"}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	var id, rejectedJSON string
+	if err := db.QueryRowContext(ctx, `SELECT id, rejected_json FROM group_commands`).Scan(&id, &rejectedJSON); err != nil {
+		t.Fatal(err)
+	}
+	var rejected []struct{ DeviceID string }
+	if err := json.Unmarshal([]byte(rejectedJSON), &rejected); err != nil {
+		t.Fatal(err)
+	}
+	if len(rejected) != 2 || rejected[0].DeviceID != "b" || rejected[1].DeviceID != "c" {
+		t.Fatalf("rejected = %s", rejectedJSON)
+	}
+	st, err := a.GroupCommandStatus(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Members) != 3 || st.Members[0].DeviceID != "a" || st.Counts[app.MemberRejected] != 2 {
+		t.Fatalf("members = %+v", st.Members)
+	}
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// RunHistoryPruning периодически удаляет историю телеметрии старше retention.
+// retention <= 0 — история хранится бессрочно.
+func (a *App) RunHistoryPruning(ctx context.Context, interval, retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n, err := a.History.DeleteBefore(ctx, time.Now().Add(-retention))
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("history_prune_error", "err", err)
+				}
+				continue
+			}
+			if n > 0 {
+				slog.Info("history_pruned", "rows", n)
+			}
+		}
+	}
+}
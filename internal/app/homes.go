@@ -0,0 +1,59 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+var (
+	ErrHomeNotFound  = errors.New("home not found")
+	ErrRoomNotFound  = errors.New("room not found")
+	ErrRoomNotInHome = errors.New("room belongs to another home")
+	ErrHomeNotEmpty  = errors.New("home has devices")
+	ErrDefaultHome   = errors.New("default home cannot be deleted")
+)
+
+// DeleteHome удаляет пустой дом вместе с комнатами. Отсутствующий дом — не ошибка.
+func (a *App) DeleteHome(ctx context.Context, id string) error {
+	if id == storage.DefaultHomeID {
+		return ErrDefaultHome
+	}
+	deleted, err := a.Homes.DeleteEmpty(ctx, id)
+	if err != nil || deleted {
+		return err
+	}
+	if _, err := a.Homes.Get(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	return ErrHomeNotEmpty
+}
+
+// checkPlacement проверяет, что дом существует, а комната (если задана) в нём.
+func (a *App) checkPlacement(ctx context.Context, homeID, roomID string) error {
+	if _, err := a.Homes.Get(ctx, homeID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrHomeNotFound
+		}
+		return err
+	}
+	if roomID == "" {
+		return nil
+	}
+	rm, err := a.Rooms.Get(ctx, roomID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRoomNotFound
+		}
+		return err
+	}
+	if rm.HomeID != homeID {
+		return ErrRoomNotInHome
+	}
+	return nil
+}
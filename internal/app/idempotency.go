@@ -0,0 +1,31 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// RunIdempotencyPruning периодически удаляет сохранённые ответы с истёкшим TTL.
+func (a *App) RunIdempotencyPruning(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n, err := a.Idempotency.DeleteExpired(ctx, time.Now())
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("idempotency_prune_error", "err", err)
+				}
+				continue
+			}
+			if n > 0 {
+				slog.Info("idempotency_pruned", "rows", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+var ErrInvalidStatus = errors.New(`status payload must be "online" or "offline"`)
+
+// Причины смены присутствия (PresenceData.Reason)
+const (
+	PresenceMessage = "message" // пришло telemetry/ack/status online
+	PresenceStatus  = "status"  // status offline (в том числе LWT брокера)
+	PresenceSilence = "silence" // нет сообщений дольше окна тишины
+)
+
+// HandleStatus обрабатывает топик .../status: "online" или "offline".
+// Устройство ставит "offline" как LWT, брокер публикует его при обрыве связи.
+func (a *App) HandleStatus(ctx context.Context, mqttHomeID, mqttDeviceID string, payload []byte) error {
+	d, err := a.Devices.GetByTopic(ctx, mqttHomeID, mqttDeviceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrDeviceNotFound
+		}
+		return err
+	}
+
+	switch string(bytes.TrimSpace(payload)) {
+	case "online":
+		return a.markSeen(ctx, d.ID)
+	case "offline":
+		changed, err := a.Devices.SetOffline(ctx, d.ID)
+		if err != nil || !changed {
+			return err
+		}
+		a.publishPresence(EventDeviceOffline, d.ID, d.LastSeenAt, PresenceStatus)
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidStatus, payload)
+	}
+}
+
+// markSeen фиксирует сообщение от устройства; при переходе в online — событие.
+func (a *App) markSeen(ctx context.Context, deviceID string) error {
+	at := time.Now().UTC()
+	cameOnline, err := a.Devices.MarkSeen(ctx, deviceID, at)
+	if err != nil || !cameOnline {
+		return err
+	}
+	a.publishPresence(EventDeviceOnline, deviceID, &at, PresenceMessage)
+	return nil
+}
+
+// RunPresenceSweep периодически переводит в offline устройства, молчащие
+// дольше silence. silence <= 0 — только по status-топику.
+func (a *App) RunPresenceSweep(ctx context.Context, interval, silence time.Duration) {
+	if silence <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			silent, err := a.Devices.SetOfflineSilentSince(ctx, time.Now().Add(-silence))
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("presence_sweep_error", "err", err)
+				}
+				continue
+			}
+			for _, d := range silent {
+				slog.Info("device_offline", "device_id", d.ID, "reason", PresenceSilence)
+				a.publishPresence(EventDeviceOffline, d.ID, d.LastSeenAt, PresenceSilence)
+			}
+		}
+	}
+}
+
+func (a *App) publishPresence(t EventType, deviceID string, lastSeen *time.Time, reason string) {
+	a.Events.Publish(Event{
+		Type:     t,
+		DeviceID: deviceID,
+		Data:     PresenceData{Online: t == EventDeviceOnline, LastSeenAt: lastSeen, Reason: reason},
+	})
+}
@@ -0,0 +1,376 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+var (
+	ErrSceneNotFound    = errors.New("scene not found")
+	ErrSceneRunNotFound = errors.New("scene run not found")
+	ErrInvalidScene     = errors.New("invalid scene")
+)
+
+// Ограничение на размер сцены, чтобы один запуск не растянулся на сотни команд
+const maxSceneSteps = 100
+
+// Статусы шага запуска, пока команды нет; у отправленного шага — статус команды
+const (
+	StepScheduled = "scheduled" // ждёт своей очереди (delay)
+	StepRejected  = MemberRejected
+	StepSkipped   = "skipped" // запуск отменён или прерван до этого шага
+	StepDeleted   = "deleted" // команда удалена вместе с устройством
+
+	stepDispatched = "dispatched"
+)
+
+// SceneSource — значение commands.source для команд сцены.
+func SceneSource(sceneID string) string { return "scene:" + sceneID }
+
+// SceneStep — шаг сцены (scenes.steps_json). Шаги выполняются по порядку,
+// Delay — пауза перед шагом относительно предыдущего.
+type SceneStep struct {
+	DeviceID string          `json:"deviceId"`
+	Action   string          `json:"action"`
+	Params   json.RawMessage `json:"params,omitempty"`
+	Delay    string          `json:"delay,omitempty"`
+}
+
+// SceneRunStep — результат шага (scene_runs.steps_json).
+type SceneRunStep struct {
+	DeviceID     string     `json:"deviceId"`
+	Action       string     `json:"action"`
+	Delay        string     `json:"delay,omitempty"`
+	Status       string     `json:"status"`
+	CommandID    string     `json:"commandId,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	DispatchedAt *time.Time `json:"dispatchedAt,omitempty"`
+}
+
+type SceneRunStatus struct {
+	storage.SceneRun
+	Steps   []SceneRunStep // у отправленных шагов — текущий статус команды
+	Outcome string
+	Counts  map[string]int // по статусам шагов
+}
+
+// activeScene — запуск с отложенными шагами; новый запуск сцены отменяет прежний.
+type activeScene struct {
+	runID  string
+	cancel context.CancelFunc
+}
+
+func ParseSceneSteps(s storage.Scene) ([]SceneStep, error) {
+	var steps []SceneStep
+	if err := json.Unmarshal([]byte(s.StepsJSON), &steps); err != nil {
+		return nil, fmt.Errorf("scene %s steps: %w", s.ID, err)
+	}
+	return steps, nil
+}
+
+func EncodeSceneSteps(steps []SceneStep) string {
+	if steps == nil {
+		steps = []SceneStep{}
+	}
+	b, _ := json.Marshal(steps)
+	return string(b)
+}
+
+// CheckSceneSteps проверяет шаги: каждый должен проходить CheckCommand.
+func (a *App) CheckSceneSteps(ctx context.Context, steps []SceneStep) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("%w: at least one step required", ErrInvalidScene)
+	}
+	if len(steps) > maxSceneSteps {
+		return fmt.Errorf("%w: at most %d steps allowed", ErrInvalidScene, maxSceneSteps)
+	}
+
+	for i, st := range steps {
+		if st.DeviceID == "" || st.Action == "" {
+			return fmt.Errorf("%w: steps[%d].deviceId and action required", ErrInvalidScene, i)
+		}
+		if st.Delay != "" {
+			if d, err := time.ParseDuration(st.Delay); err != nil || d < 0 {
+				return fmt.Errorf("%w: steps[%d].delay must be a duration", ErrInvalidScene, i)
+			}
+		}
+
+		if _, _, err := a.CheckCommand(ctx, st.DeviceID, st.Action, st.Params); err != nil {
+			switch {
+			case errors.Is(err, ErrDeviceNotFound):
+				return fmt.Errorf("%w: steps[%d]: unknown device: %s", ErrInvalidScene, i, st.DeviceID)
+			case errors.Is(err, ErrUnsupportedAction), errors.Is(err, ErrInvalidParams):
+				return fmt.Errorf("%w: steps[%d]: %v", ErrInvalidScene, i, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteScene отменяет незавершённый запуск и удаляет сцену вместе с историей запусков.
+func (a *App) DeleteScene(ctx context.Context, id string) (bool, error) {
+	a.cancelScene(id)
+	return a.Scenes.Delete(ctx, id)
+}
+
+// ActivateScene запускает сцену: шаги без задержки отправляются сразу,
+// остальные — в фоне по своим delay. Возвращает запуск на момент ответа.
+// Незавершённый предыдущий запуск той же сцены отменяется. limit (может быть nil)
+// проверяется при отправке каждого шага; отказ — шаг rejected.
+func (a *App) ActivateScene(ctx context.Context, sceneID, source string, limit DeviceLimit) (SceneRunStatus, error) {
+	sc, err := a.Scenes.Get(ctx, sceneID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SceneRunStatus{}, ErrSceneNotFound
+		}
+		return SceneRunStatus{}, err
+	}
+	steps, err := ParseSceneSteps(sc)
+	if err != nil {
+		return SceneRunStatus{}, err
+	}
+	if source == "" {
+		source = SourceAPI
+	}
+
+	r := &sceneRun{
+		app:   a,
+		steps: steps,
+		limit: limit,
+		run: storage.SceneRun{
+			ID:        newID(),
+			SceneID:   sc.ID,
+			Source:    source,
+			Status:    storage.SceneRunRunning,
+			StartedAt: time.Now().UTC(),
+		},
+	}
+	r.results = make([]SceneRunStep, 0, len(steps))
+	for _, st := range steps {
+		r.results = append(r.results, SceneRunStep{DeviceID: st.DeviceID, Action: st.Action, Delay: st.Delay, Status: StepScheduled})
+	}
+	r.encode()
+	if err := a.SceneRuns.Create(ctx, r.run); err != nil {
+		return SceneRunStatus{}, err
+	}
+
+	// запуск не должен обрываться вместе с HTTP-запросом
+	ctx = context.WithoutCancel(ctx)
+	runCtx, cancel := context.WithCancel(ctx)
+	a.startScene(sc.ID, r.run.ID, cancel)
+
+	i := 0
+	for ; i < len(steps) && stepDelay(steps[i]) == 0; i++ {
+		r.dispatch(ctx, i)
+	}
+	if i == len(steps) {
+		r.finish(ctx, storage.SceneRunCompleted)
+		a.endScene(sc.ID, r.run.ID)
+		cancel()
+	} else {
+		r.save(ctx)
+	}
+
+	snapshot := r.run
+	if i < len(steps) {
+		go r.continueFrom(runCtx, i)
+	}
+	return a.sceneRunStatus(ctx, snapshot)
+}
+
+func (a *App) SceneRunStatus(ctx context.Context, runID string) (SceneRunStatus, error) {
+	run, err := a.SceneRuns.Get(ctx, runID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SceneRunStatus{}, ErrSceneRunNotFound
+		}
+		return SceneRunStatus{}, err
+	}
+	return a.sceneRunStatus(ctx, run)
+}
+
+func (a *App) ListSceneRuns(ctx context.Context, sceneID string, limit int) ([]SceneRunStatus, error) {
+	runs, err := a.SceneRuns.ListByScene(ctx, sceneID, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SceneRunStatus, 0, len(runs))
+	for _, run := range runs {
+		st, err := a.sceneRunStatus(ctx, run)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// InterruptSceneRuns закрывает запуски, оставшиеся running после остановки
+// процесса: отложенные шаги уже не будут отправлены.
+func (a *App) InterruptSceneRuns(ctx context.Context) error {
+	runs, err := a.SceneRuns.ListRunning(ctx)
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		r := &sceneRun{app: a, run: run}
+		if err := json.Unmarshal([]byte(run.StepsJSON), &r.results); err != nil {
+			return fmt.Errorf("scene run %s steps: %w", run.ID, err)
+		}
+		if err := r.finish(ctx, storage.SceneRunInterrupted); err != nil {
+			return err
+		}
+		slog.Warn("scene_run_interrupted", "scene_id", run.SceneID, "run_id", run.ID)
+	}
+	return nil
+}
+
+func (a *App) sceneRunStatus(ctx context.Context, run storage.SceneRun) (SceneRunStatus, error) {
+	st := SceneRunStatus{SceneRun: run, Counts: map[string]int{}}
+	if err := json.Unmarshal([]byte(run.StepsJSON), &st.Steps); err != nil {
+		return SceneRunStatus{}, fmt.Errorf("scene run %s steps: %w", run.ID, err)
+	}
+
+	for i, s := range st.Steps {
+		if s.Status == stepDispatched {
+			c, err := a.Commands.Get(ctx, s.CommandID)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				// commands удаляются каскадно с устройством; история запуска остаётся
+				st.Steps[i].Status = StepDeleted
+			case err != nil:
+				return SceneRunStatus{}, err
+			default:
+				st.Steps[i].Status, st.Steps[i].Error = c.Status, c.Error
+			}
+		}
+		st.Counts[st.Steps[i].Status]++
+	}
+	st.Outcome = outcome(st.Counts, len(st.Steps), st.Counts[storage.CommandPending]+st.Counts[StepScheduled])
+	return st, nil
+}
+
+func (a *App) startScene(sceneID, runID string, cancel context.CancelFunc) {
+	a.scenesMu.Lock()
+	defer a.scenesMu.Unlock()
+	if prev, ok := a.activeScenes[sceneID]; ok {
+		prev.cancel()
+	}
+	a.activeScenes[sceneID] = activeScene{runID: runID, cancel: cancel}
+}
+
+func (a *App) endScene(sceneID, runID string) {
+	a.scenesMu.Lock()
+	defer a.scenesMu.Unlock()
+	if cur, ok := a.activeScenes[sceneID]; ok && cur.runID == runID {
+		delete(a.activeScenes, sceneID)
+	}
+}
+
+func (a *App) cancelScene(sceneID string) {
+	a.scenesMu.Lock()
+	defer a.scenesMu.Unlock()
+	if cur, ok := a.activeScenes[sceneID]; ok {
+		cur.cancel()
+		delete(a.activeScenes, sceneID)
+	}
+}
+
+// sceneRun — выполняющийся запуск; принадлежит одной горутине.
+type sceneRun struct {
+	app     *App
+	run     storage.SceneRun
+	steps   []SceneStep
+	results []SceneRunStep
+	limit   DeviceLimit
+}
+
+// continueFrom отправляет шаги начиная с i, выдерживая delay; ctx отменяется
+// новым запуском сцены или её удалением.
+func (r *sceneRun) continueFrom(ctx context.Context, i int) {
+	defer r.app.endScene(r.run.SceneID, r.run.ID)
+	dbCtx := context.WithoutCancel(ctx)
+
+	for ; i < len(r.steps); i++ {
+		if d := stepDelay(r.steps[i]); d > 0 {
+			t := time.NewTimer(d)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				r.finish(dbCtx, storage.SceneRunCancelled)
+				return
+			case <-t.C:
+			}
+		}
+		r.dispatch(dbCtx, i)
+		r.save(dbCtx)
+	}
+	r.finish(dbCtx, storage.SceneRunCompleted)
+}
+
+func (r *sceneRun) dispatch(ctx context.Context, i int) {
+	st := r.steps[i]
+	res := &r.results[i]
+
+	c, err := r.app.CreateCommand(ctx, CommandRequest{
+		DeviceID:      st.DeviceID,
+		Action:        st.Action,
+		Params:        st.Params,
+		Source:        SceneSource(r.run.SceneID),
+		CorrelationID: r.run.ID,
+		DeviceLimit:   r.limit,
+	})
+	now := time.Now().UTC()
+	res.DispatchedAt = &now
+
+	switch {
+	case err == nil, errors.Is(err, ErrPublish):
+		res.Status, res.CommandID = stepDispatched, c.ID
+	case errors.Is(err, ErrDeviceNotFound), errors.Is(err, ErrUnsupportedAction), errors.Is(err, ErrInvalidParams),
+		errors.Is(err, ErrRateLimited):
+		res.Status, res.Error = StepRejected, err.Error()
+	default:
+		res.Status, res.Error = storage.CommandFailed, err.Error()
+		slog.Error("scene_step_error", "scene_id", r.run.SceneID, "run_id", r.run.ID, "step", i, "err", err)
+	}
+}
+
+// finish закрывает запуск: неотправленные шаги — skipped.
+func (r *sceneRun) finish(ctx context.Context, status string) error {
+	for i := range r.results {
+		if r.results[i].Status == StepScheduled {
+			r.results[i].Status = StepSkipped
+		}
+	}
+	now := time.Now().UTC()
+	r.run.Status, r.run.FinishedAt = status, &now
+	return r.save(ctx)
+}
+
+func (r *sceneRun) save(ctx context.Context) error {
+	r.encode()
+	if err := r.app.SceneRuns.Update(ctx, r.run); err != nil {
+		slog.Error("scene_run_save_error", "scene_id", r.run.SceneID, "run_id", r.run.ID, "err", err)
+		return err
+	}
+	return nil
+}
+
+func (r *sceneRun) encode() {
+	b, _ := json.Marshal(r.results)
+	r.run.StepsJSON = string(b)
+}
+
+// stepDelay — delay шага; в БД лежат только проверенные CheckSceneSteps значения.
+func stepDelay(st SceneStep) time.Duration {
+	d, _ := time.ParseDuration(st.Delay)
+	return d
+}
@@ -0,0 +1,65 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+	"github.com/ArthurGuatsaev/smarthome/internal/testutil"
+)
+
+func TestSceneRunAfterDeviceDeleted(t *testing.T) {
+	ctx := context.Background()
+	a := testutil.NewApp(t, nil)
+
+	now := time.Now().UTC()
+	for _, id := range []string{"lamp", "fan"} {
+		d := storage.Device{ID: id, Name: id, Type: "switch", MQTTDeviceID: id, Capabilities: `["on_off"]`, CreatedAt: now}
+		if _, err := a.CreateDevice(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sc := storage.Scene{
+		ID:        "evening",
+		Name:      "evening",
+		StepsJSON: `[{"deviceId":"lamp","action":"turn_on"},{"deviceId":"fan","action":"turn_on"}]`,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := a.Scenes.Create(ctx, sc); err != nil {
+		t.Fatal(err)
+	}
+	run, err := a.ActivateScene(ctx, sc.ID, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// команды шага удаляются каскадно вместе с устройством
+	if err := a.DeleteDevice(ctx, "fan"); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := a.SceneRunStatus(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("run status: %v", err)
+	}
+	if got := st.Steps[1].Status; got != app.StepDeleted {
+		t.Fatalf("deleted device step status = %q, want %q", got, app.StepDeleted)
+	}
+	if got := st.Steps[0].Status; got != storage.CommandPending {
+		t.Fatalf("lamp step status = %q, want %q", got, storage.CommandPending)
+	}
+	if st.Counts[app.StepDeleted] != 1 || st.Outcome != app.OutcomePending {
+		t.Fatalf("counts = %v, outcome = %s", st.Counts, st.Outcome)
+	}
+
+	runs, err := a.ListSceneRuns(ctx, sc.ID, 10)
+	if err != nil {
+		t.Fatalf("list runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Steps[1].Status != app.StepDeleted {
+		t.Fatalf("runs = %+v", runs)
+	}
+}
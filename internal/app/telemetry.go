@@ -0,0 +1,85 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+var ErrInvalidTelemetry = errors.New("invalid telemetry payload")
+
+// HandleTelemetry сохраняет последнее состояние устройства из телеметрии
+// и дописывает его в историю.
+// Payload должен быть непустым JSON-объектом. Служебное поле "commandId"
+// (состояние изменилось по команде) в state не сохраняется.
+func (a *App) HandleTelemetry(ctx context.Context, mqttHomeID, mqttDeviceID string, payload []byte) error {
+	state, commandID, err := normalizeState(payload)
+	if err != nil {
+		return err
+	}
+
+	d, err := a.Devices.GetByTopic(ctx, mqttHomeID, mqttDeviceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrDeviceNotFound
+		}
+		return err
+	}
+	if err := a.markSeen(ctx, d.ID); err != nil {
+		return err
+	}
+
+	st := storage.DeviceState{
+		DeviceID:  d.ID,
+		StateJSON: state,
+		UpdatedAt: time.Now().UTC(),
+	}
+	if err := a.States.Upsert(ctx, st); err != nil {
+		return err
+	}
+	// история вторична: без неё текущее состояние и событие всё равно нужны
+	if err := a.History.Append(ctx, st); err != nil {
+		slog.Warn("history_append_error", "device_id", d.ID, "err", err)
+	}
+
+	a.Events.Publish(Event{
+		Type:      EventDeviceStateChanged,
+		DeviceID:  d.ID,
+		CommandID: commandID,
+		At:        st.UpdatedAt,
+		Data:      StateChangedData{State: json.RawMessage(st.StateJSON)},
+	})
+	return nil
+}
+
+func normalizeState(payload []byte) (state, commandID string, err error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &obj); err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidTelemetry, err)
+	}
+
+	if raw, ok := obj["commandId"]; ok {
+		if err := json.Unmarshal(raw, &commandID); err != nil {
+			return "", "", fmt.Errorf("%w: commandId must be a string", ErrInvalidTelemetry)
+		}
+		delete(obj, "commandId")
+		// ключи без служебного поля; порядок ключей map при Marshal — отсортированный
+		payload, _ = json.Marshal(obj)
+	}
+	if len(obj) == 0 {
+		return "", "", fmt.Errorf("%w: empty object", ErrInvalidTelemetry)
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, payload); err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidTelemetry, err)
+	}
+	return buf.String(), commandID, nil
+}
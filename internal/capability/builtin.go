@@ -0,0 +1,139 @@
+package capability
+
+// Builtin — реестр стандартных возможностей и типов устройств.
+func Builtin() *Registry {
+	r := NewRegistry()
+
+	r.Register(Definition{
+		Name:        "on_off",
+		Description: "Включение и выключение",
+		Actions: []Action{
+			{Name: "turn_on", Params: noParams()},
+			{Name: "turn_off", Params: noParams()},
+			{Name: "toggle", Params: noParams()},
+		},
+		StateFields: map[string]string{"on": "boolean"},
+	})
+	r.Register(Definition{
+		Name:        "brightness",
+		Description: "Яркость, %",
+		Actions: []Action{
+			{Name: "set_brightness", Params: object(map[string]*Schema{
+				"brightness": integer(0, 100),
+			}, "brightness")},
+		},
+		StateFields: map[string]string{"brightness": "integer"},
+	})
+	r.Register(Definition{
+		Name:        "color_temperature",
+		Description: "Цветовая температура, K",
+		Actions: []Action{
+			{Name: "set_color_temperature", Params: object(map[string]*Schema{
+				"kelvin": integer(1500, 9000),
+			}, "kelvin")},
+		},
+		StateFields: map[string]string{"kelvin": "integer"},
+	})
+	r.Register(Definition{
+		Name:        "color",
+		Description: "Цвет RGB",
+		Actions: []Action{
+			{Name: "set_color", Params: object(map[string]*Schema{
+				"r": integer(0, 255),
+				"g": integer(0, 255),
+				"b": integer(0, 255),
+			}, "r", "g", "b")},
+		},
+		StateFields: map[string]string{"color": "object"},
+	})
+	r.Register(Definition{
+		Name:        "thermostat",
+		Description: "Целевая температура и режим",
+		Actions: []Action{
+			{Name: "set_target_temperature", Params: object(map[string]*Schema{
+				"temperature": number(5, 35),
+			}, "temperature")},
+			{Name: "set_mode", Params: object(map[string]*Schema{
+				"mode": enum("off", "heat", "cool", "auto"),
+			}, "mode")},
+		},
+		StateFields: map[string]string{"targetTemperature": "number", "mode": "string"},
+	})
+	r.Register(Definition{
+		Name:        "lock",
+		Description: "Замок",
+		Actions: []Action{
+			{Name: "lock", Params: noParams()},
+			{Name: "unlock", Params: noParams()},
+		},
+		StateFields: map[string]string{"locked": "boolean"},
+	})
+	r.Register(Definition{
+		Name:        "cover",
+		Description: "Шторы и жалюзи, положение в %",
+		Actions: []Action{
+			{Name: "open", Params: noParams()},
+			{Name: "close", Params: noParams()},
+			{Name: "stop", Params: noParams()},
+			{Name: "set_position", Params: object(map[string]*Schema{
+				"position": integer(0, 100),
+			}, "position")},
+		},
+		StateFields: map[string]string{"position": "integer"},
+	})
+
+	// датчики только сообщают состояние
+	r.Register(Definition{
+		Name:        "temperature_sensor",
+		StateFields: map[string]string{"temperature": "number"},
+	})
+	r.Register(Definition{
+		Name:        "humidity_sensor",
+		StateFields: map[string]string{"humidity": "number"},
+	})
+	r.Register(Definition{
+		Name:        "motion_sensor",
+		StateFields: map[string]string{"motion": "boolean"},
+	})
+	r.Register(Definition{
+		Name:        "contact_sensor",
+		StateFields: map[string]string{"open": "boolean"},
+	})
+
+	r.RegisterType(DeviceType{
+		Name:         "light",
+		Capabilities: []string{"on_off", "brightness", "color_temperature", "color"},
+		Defaults:     []string{"on_off"},
+	})
+	r.RegisterType(DeviceType{
+		Name:         "switch",
+		Capabilities: []string{"on_off"},
+		Defaults:     []string{"on_off"},
+	})
+	r.RegisterType(DeviceType{
+		Name:         "plug",
+		Capabilities: []string{"on_off"},
+		Defaults:     []string{"on_off"},
+	})
+	r.RegisterType(DeviceType{
+		Name:         "thermostat",
+		Capabilities: []string{"thermostat", "temperature_sensor", "humidity_sensor"},
+		Defaults:     []string{"thermostat", "temperature_sensor"},
+	})
+	r.RegisterType(DeviceType{
+		Name:         "lock",
+		Capabilities: []string{"lock"},
+		Defaults:     []string{"lock"},
+	})
+	r.RegisterType(DeviceType{
+		Name:         "cover",
+		Capabilities: []string{"cover"},
+		Defaults:     []string{"cover"},
+	})
+	r.RegisterType(DeviceType{
+		Name:         "sensor",
+		Capabilities: []string{"temperature_sensor", "humidity_sensor", "motion_sensor", "contact_sensor"},
+	})
+
+	return r
+}
@@ -0,0 +1,182 @@
+// Package capability описывает возможности устройств: какие команды они
+// принимают (с JSON-схемой параметров) и какие поля сообщают в телеметрии.
+package capability
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+var (
+	ErrUnknownDeviceType    = errors.New("unknown device type")
+	ErrUnknownCapability    = errors.New("unknown capability")
+	ErrCapabilityNotAllowed = errors.New("capability not allowed for device type")
+	ErrCapabilitiesRequired = errors.New("capabilities required for device type")
+)
+
+type Action struct {
+	Name   string  `json:"name"`
+	Params *Schema `json:"params"`
+}
+
+// Definition — одна возможность (on_off, brightness, ...).
+type Definition struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Actions     []Action          `json:"actions"`
+	StateFields map[string]string `json:"stateFields"` // поле телеметрии -> тип JSON
+}
+
+// DeviceType — набор возможностей, допустимых для типа устройства.
+// Defaults назначаются устройству, если capabilities не переданы.
+type DeviceType struct {
+	Name         string
+	Capabilities []string
+	Defaults     []string
+}
+
+type Registry struct {
+	caps  map[string]Definition
+	types map[string]DeviceType
+}
+
+func NewRegistry() *Registry {
+	return &Registry{caps: map[string]Definition{}, types: map[string]DeviceType{}}
+}
+
+// Register добавляет возможность; повторная регистрация имени — ошибка программиста.
+func (r *Registry) Register(d Definition) {
+	if _, ok := r.caps[d.Name]; ok {
+		panic("capability: duplicate " + d.Name)
+	}
+	r.caps[d.Name] = d
+}
+
+// RegisterType добавляет тип устройства; все его возможности должны быть зарегистрированы.
+func (r *Registry) RegisterType(t DeviceType) {
+	for _, c := range append(slices.Clone(t.Capabilities), t.Defaults...) {
+		if _, ok := r.caps[c]; !ok {
+			panic("capability: type " + t.Name + " uses unregistered " + c)
+		}
+	}
+	r.types[t.Name] = t
+}
+
+func (r *Registry) Lookup(name string) (Definition, bool) {
+	d, ok := r.caps[name]
+	return d, ok
+}
+
+func (r *Registry) Type(name string) (DeviceType, bool) {
+	t, ok := r.types[name]
+	return t, ok
+}
+
+// Definitions возвращает все возможности, отсортированные по имени.
+func (r *Registry) Definitions() []Definition {
+	out := make([]Definition, 0, len(r.caps))
+	for _, d := range r.caps {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// TypesWith возвращает типы устройств, которым доступна возможность capability.
+func (r *Registry) TypesWith(capability string) []string {
+	var out []string
+	for _, t := range r.types {
+		if slices.Contains(t.Capabilities, capability) {
+			out = append(out, t.Name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// CheckDevice проверяет capabilities устройства. У зарегистрированного типа все
+// они должны быть ему разрешены; пустой caps заменяется на Defaults типа (если их
+// нет — ErrCapabilitiesRequired). Незарегистрированный тип (устаревший формат)
+// принимается только с явным списком зарегистрированных возможностей.
+// Имена действий вместо возможностей нужно заранее привести через Normalize.
+func (r *Registry) CheckDevice(deviceType string, caps []string) ([]string, error) {
+	t, known := r.types[deviceType]
+	if len(caps) == 0 {
+		if !known {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownDeviceType, deviceType)
+		}
+		if len(t.Defaults) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrCapabilitiesRequired, deviceType)
+		}
+		return slices.Clone(t.Defaults), nil
+	}
+	for _, c := range caps {
+		if _, ok := r.caps[c]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCapability, c)
+		}
+		if known && !slices.Contains(t.Capabilities, c) {
+			return nil, fmt.Errorf("%w: %s for %s", ErrCapabilityNotAllowed, c, deviceType)
+		}
+	}
+	return caps, nil
+}
+
+// Provider возвращает возможность, в которую входит действие action.
+func (r *Registry) Provider(action string) (string, bool) {
+	for _, d := range r.caps {
+		for _, a := range d.Actions {
+			if a.Name == action {
+				return d.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Normalize приводит устаревший формат (до реестра capabilities хранили имена
+// действий: ["turn_on","turn_off"]) к именам возможностей (["on_off"]).
+// replaced — заменённые имена действий; неизвестные имена остаются как есть.
+func (r *Registry) Normalize(caps []string) (out, replaced []string) {
+	out = make([]string, 0, len(caps))
+	for _, c := range caps {
+		if _, ok := r.caps[c]; !ok {
+			if p, ok := r.Provider(c); ok {
+				replaced = append(replaced, c)
+				c = p
+			}
+		}
+		if !slices.Contains(out, c) {
+			out = append(out, c)
+		}
+	}
+	return out, replaced
+}
+
+// Deprecations описывает устаревшие части описания устройства: имена
+// действий вместо возможностей и незарегистрированный тип.
+func (r *Registry) Deprecations(deviceType string, caps []string) []string {
+	var out []string
+	_, replaced := r.Normalize(caps)
+	for _, a := range replaced {
+		p, _ := r.Provider(a)
+		out = append(out, fmt.Sprintf("capability '%s' is an action name, use '%s'", a, p))
+	}
+	if _, ok := r.types[deviceType]; !ok && deviceType != "" {
+		out = append(out, fmt.Sprintf("device type '%s' is not registered", deviceType))
+	}
+	return out
+}
+
+// ResolveAction ищет действие среди возможностей устройства и возвращает схему параметров.
+func (r *Registry) ResolveAction(caps []string, action string) (schema *Schema, ok bool) {
+	for _, c := range caps {
+		for _, a := range r.caps[c].Actions {
+			if a.Name == action {
+				return a.Params, true
+			}
+		}
+	}
+	return nil, false
+}
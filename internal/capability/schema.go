@@ -0,0 +1,123 @@
+package capability
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Schema — подмножество JSON Schema, достаточное для параметров команд:
+// type (object|integer|number|boolean|string), properties, required,
+// additionalProperties, minimum/maximum, enum.
+type Schema struct {
+	Type                 string             `json:"type"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+}
+
+// Validate проверяет JSON-документ по схеме; ошибка содержит путь к полю.
+func (s *Schema) Validate(doc []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("invalid json: %v", err)
+	}
+	return s.validate("params", v)
+}
+
+func (s *Schema) validate(path string, v any) error {
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: must be an object", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s.%s: required", path, name)
+			}
+		}
+		for name, fv := range obj {
+			ps, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s.%s: unknown field", path, name)
+				}
+				continue
+			}
+			if err := ps.validate(path+"."+name, fv); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: must be a %s", path, s.Type)
+		}
+		f, err := strconv.ParseFloat(n.String(), 64)
+		if err != nil {
+			return fmt.Errorf("%s: must be a %s", path, s.Type)
+		}
+		if s.Type == "integer" && f != math.Trunc(f) {
+			return fmt.Errorf("%s: must be an integer", path)
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s: must be >= %v", path, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s: must be <= %v", path, *s.Maximum)
+		}
+		return nil
+
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: must be a boolean", path)
+		}
+		return nil
+
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: must be a string", path)
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return fmt.Errorf("%s: must be one of %v", path, s.Enum)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("%s: unsupported schema type %q", path, s.Type)
+	}
+}
+
+// Конструкторы схем для описаний в builtin.go.
+
+func object(props map[string]*Schema, required ...string) *Schema {
+	no := false
+	return &Schema{Type: "object", Properties: props, Required: required, AdditionalProperties: &no}
+}
+
+func noParams() *Schema { return object(nil) }
+
+func integer(min, max float64) *Schema {
+	return &Schema{Type: "integer", Minimum: &min, Maximum: &max}
+}
+
+func number(min, max float64) *Schema {
+	return &Schema{Type: "number", Minimum: &min, Maximum: &max}
+}
+
+func enum(values ...string) *Schema {
+	return &Schema{Type: "string", Enum: values}
+}
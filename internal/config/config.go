@@ -1,6 +1,7 @@
 package config
 
 import (
+	"math"
 	"os"
 	"strconv"
 	"time"
@@ -15,6 +16,43 @@ type Config struct {
 	IdleTimeout     time.Duration
 	DBPath          string
 	APIKey          string
+
+	MQTTBrokerURL string
+	MQTTClientID  string
+	MQTTUsername  string
+	MQTTPassword  string
+	MQTTHomeID    string
+
+	CommandTimeout       time.Duration
+	CommandSweepInterval time.Duration
+
+	// Устройство считается offline, если молчит дольше PresenceTimeout (0 — только по status)
+	PresenceTimeout       time.Duration
+	PresenceSweepInterval time.Duration
+
+	// История телеметрии: сколько хранить (0 — бессрочно) и как часто чистить
+	HistoryRetention     time.Duration
+	HistoryPruneInterval time.Duration
+
+	// Расписания: часовой пояс по умолчанию (IANA, "Local" — системный)
+	// и координаты дома для sunrise/sunset (NaN — не заданы)
+	Timezone  string
+	Latitude  float64
+	Longitude float64
+
+	// Вебхуки: таймаут попытки, число попыток до dead-letter и сколько хранить журнал доставленных
+	WebhookTimeout      time.Duration
+	WebhookMaxAttempts  int
+	WebhookLogRetention time.Duration
+
+	// Сколько хранить ответы на POST с Idempotency-Key (0 — заголовок игнорируется)
+	IdempotencyTTL time.Duration
+
+	// Лимиты отправки команд (токенов/сек и ёмкость); 0 — без ограничения
+	RateLimitKeyRPS      float64
+	RateLimitKeyBurst    int
+	RateLimitDeviceRPS   float64
+	RateLimitDeviceBurst int
 }
 
 func Load() Config {
@@ -27,7 +65,39 @@ func Load() Config {
 		WriteTimeout: getenvDuration("HTTP_WRITE_TIMEOUT", 10*time.Second),
 		IdleTimeout:  getenvDuration("HTTP_IDLE_TIMEOUT", 60*time.Second),
 		DBPath:       getenv("DB_PATH", "./data/smarthome.db"),
-		APIKey:       getenv("API_KEY", "devkey"),
+		// bootstrap-ключ администратора; остальные ключи — в БД (/api/v1/keys)
+		APIKey: getenv("API_KEY", ""),
+
+		MQTTBrokerURL: getenv("MQTT_BROKER_URL", "tcp://localhost:1883"),
+		MQTTClientID:  getenv("MQTT_CLIENT_ID", "smarthome-server"),
+		MQTTUsername:  getenv("MQTT_USERNAME", ""),
+		MQTTPassword:  getenv("MQTT_PASSWORD", ""),
+		// mqtt-id дома по умолчанию при первом запуске; остальные дома — через /api/v1/homes
+		MQTTHomeID: getenv("MQTT_HOME_ID", "1"),
+
+		CommandTimeout:       getenvDuration("COMMAND_TIMEOUT", 30*time.Second),
+		CommandSweepInterval: getenvInterval("COMMAND_SWEEP_INTERVAL", 5*time.Second),
+
+		PresenceTimeout:       getenvDuration("PRESENCE_TIMEOUT", 5*time.Minute),
+		PresenceSweepInterval: getenvInterval("PRESENCE_SWEEP_INTERVAL", 30*time.Second),
+
+		HistoryRetention:     getenvDuration("HISTORY_RETENTION", 30*24*time.Hour),
+		HistoryPruneInterval: getenvInterval("HISTORY_PRUNE_INTERVAL", time.Hour),
+
+		Timezone:  getenv("TIMEZONE", "Local"),
+		Latitude:  getenvFloat("LATITUDE", math.NaN()),
+		Longitude: getenvFloat("LONGITUDE", math.NaN()),
+
+		WebhookTimeout:      getenvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts:  getenvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookLogRetention: getenvDuration("WEBHOOK_LOG_RETENTION", 7*24*time.Hour),
+
+		IdempotencyTTL: getenvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+
+		RateLimitKeyRPS:      getenvFloat("RATE_LIMIT_KEY_RPS", 10),
+		RateLimitKeyBurst:    getenvInt("RATE_LIMIT_KEY_BURST", 20),
+		RateLimitDeviceRPS:   getenvFloat("RATE_LIMIT_DEVICE_RPS", 2),
+		RateLimitDeviceBurst: getenvInt("RATE_LIMIT_DEVICE_BURST", 5),
 	}
 }
 
@@ -53,3 +123,26 @@ func getenvDuration(key string, def time.Duration) time.Duration {
 	}
 	return def
 }
+
+// getenvInterval — период фонового воркера: time.NewTicker паникует
+// на значениях <= 0, поэтому они заменяются значением по умолчанию.
+func getenvInterval(key string, def time.Duration) time.Duration {
+	if d := getenvDuration(key, def); d > 0 {
+		return d
+	}
+	return def
+}
+
+func getenvInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func getenvFloat(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return v
+	}
+	return def
+}
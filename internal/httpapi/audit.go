@@ -0,0 +1,182 @@
+package httpapi
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+const (
+	auditDefaultLimit = 100
+	auditMaxLimit     = 1000
+	auditWriteTimeout = 2 * time.Second
+	anonymousActor    = "anonymous"
+)
+
+type auditEntryDTO struct {
+	ID        int64  `json:"id"`
+	At        string `json:"at"`
+	Actor     string `json:"actor"`
+	RequestID string `json:"requestId"`
+	Method    string `json:"method"`
+	Route     string `json:"route"`
+	Resource  string `json:"resource"`
+	Status    int    `json:"status"`
+	Outcome   string `json:"outcome"`
+}
+
+// Audit пишет в audit_log каждый изменяющий запрос (POST/PUT/PATCH/DELETE),
+// включая отклонённые. route возвращает шаблон маршрута запроса.
+// Ресурс уточняют обработчики через setAuditResource, иначе пишется путь.
+func Audit(repo *storage.AuditRepo, route func(*http.Request) string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ww := &wrapWriter{ResponseWriter: w, status: 200}
+			next.ServeHTTP(ww, r)
+
+			info := requestInfoFrom(r.Context())
+			e := storage.AuditEntry{
+				At:       time.Now().UTC(),
+				Actor:    anonymousActor,
+				Method:   r.Method,
+				Route:    route(r),
+				Resource: r.URL.Path,
+				Status:   ww.status,
+				Outcome:  auditOutcome(ww.status),
+			}
+			e.RequestID, _ = r.Context().Value(requestIDKey).(string)
+			if info != nil {
+				if info.actor != "" {
+					e.Actor = info.actor
+				}
+				if info.resource != "" {
+					e.Resource = info.resource
+				}
+			}
+			writeAudit(r.Context(), repo, e)
+		})
+	}
+}
+
+// writeAudit не зависит от отмены запроса: клиент мог уже отключиться.
+func writeAudit(ctx context.Context, repo *storage.AuditRepo, e storage.AuditEntry) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), auditWriteTimeout)
+	defer cancel()
+
+	if err := repo.Append(ctx, e); err != nil {
+		slog.Error("audit_write_error", "err", err, "req_id", e.RequestID)
+	}
+}
+
+func auditOutcome(status int) string {
+	if status < 400 {
+		return "success"
+	}
+	return "failure"
+}
+
+// setAuditResource уточняет ресурс запроса для журнала: "device:<id>".
+func setAuditResource(r *http.Request, kind, id string) {
+	if info := requestInfoFrom(r.Context()); info != nil {
+		info.resource = kind + ":" + id
+	}
+}
+
+// routePattern — шаблон маршрута ServeMux для запроса ("" если не найден).
+func (s *Server) routePattern(r *http.Request) string {
+	_, pattern := s.mux.Handler(r)
+	return pattern
+}
+
+// auditWS записывает команду, отправленную через WebSocket.
+func (s *Server) auditWS(r *http.Request, deviceID string, c storage.Command, err error) {
+	status := http.StatusAccepted
+	if err != nil {
+		status, _ = commandErrorCode(err)
+	}
+	e := storage.AuditEntry{
+		At:       time.Now().UTC(),
+		Actor:    anonymousActor,
+		Method:   "WS",
+		Route:    "command",
+		Resource: "device:" + deviceID,
+		Status:   status,
+		Outcome:  auditOutcome(status),
+	}
+	if p, ok := PrincipalFrom(r.Context()); ok {
+		e.Actor = p.Name
+	}
+	if c.ID != "" {
+		e.Resource = "command:" + c.ID
+	}
+	e.RequestID, _ = r.Context().Value(requestIDKey).(string)
+	writeAudit(r.Context(), s.app.Audit, e)
+}
+
+// handleAuditList: ?from=&to= (RFC3339), ?actor=, ?before=<id>, ?limit=
+func (s *Server) handleAuditList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := storage.AuditFilter{Actor: q.Get("actor"), Limit: auditDefaultLimit}
+
+	var err error
+	if f.From, err = parseTimeParam(q.Get("from")); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "from must be RFC3339")
+		return
+	}
+	if f.To, err = parseTimeParam(q.Get("to")); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "to must be RFC3339")
+		return
+	}
+	if v := q.Get("before"); v != "" {
+		if f.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil || f.BeforeID <= 0 {
+			writeError(w, http.StatusBadRequest, "bad_request", "before must be a positive id")
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit <= 0 || f.Limit > auditMaxLimit {
+			writeError(w, http.StatusBadRequest, "bad_request", "limit must be 1.."+strconv.Itoa(auditMaxLimit))
+			return
+		}
+	}
+
+	items, err := s.app.Audit.List(r.Context(), f)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	out := make([]auditEntryDTO, 0, len(items))
+	for _, e := range items {
+		out = append(out, auditEntryDTO{
+			ID:        e.ID,
+			At:        e.At.UTC().Format(time.RFC3339Nano),
+			Actor:     e.Actor,
+			RequestID: e.RequestID,
+			Method:    e.Method,
+			Route:     e.Route,
+			Resource:  e.Resource,
+			Status:    e.Status,
+			Outcome:   e.Outcome,
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func parseTimeParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, v)
+}
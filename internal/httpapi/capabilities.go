@@ -0,0 +1,44 @@
+package httpapi
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/capability"
+)
+
+type capabilityDTO struct {
+	capability.Definition
+	DeviceTypes []string `json:"deviceTypes"` // типы, которым возможность доступна
+}
+
+// handleCapabilitiesList отдаёт реестр возможностей; ?deviceType= — только доступные типу.
+func (s *Server) handleCapabilitiesList(w http.ResponseWriter, r *http.Request) {
+	reg := s.app.Capabilities
+
+	var allowed []string
+	if t := r.URL.Query().Get("deviceType"); t != "" {
+		dt, ok := reg.Type(t)
+		if !ok {
+			writeError(w, http.StatusNotFound, "not_found", "unknown device type")
+			return
+		}
+		allowed = dt.Capabilities
+	}
+
+	out := []capabilityDTO{}
+	for _, d := range reg.Definitions() {
+		if allowed != nil && !slices.Contains(allowed, d.Name) {
+			continue
+		}
+		if d.Actions == nil {
+			d.Actions = []capability.Action{}
+		}
+		types := reg.TypesWith(d.Name)
+		if types == nil {
+			types = []string{}
+		}
+		out = append(out, capabilityDTO{Definition: d, DeviceTypes: types})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,194 @@
+package httpapi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+type createCommandReq struct {
+	Action string          `json:"action"`
+	Params json.RawMessage `json:"params"`
+}
+
+type commandDTO struct {
+	ID        string          `json:"id"`
+	DeviceID  string          `json:"deviceId"`
+	Action    string          `json:"action"`
+	Params    json.RawMessage `json:"params"`
+	Status    string          `json:"status"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt string          `json:"createdAt"`
+	AckedAt   *string         `json:"ackedAt"`
+
+	Source         string `json:"source"`
+	CorrelationID  string `json:"correlationId"`
+	GroupCommandID string `json:"groupCommandId,omitempty"`
+}
+
+func (s *Server) handleCommandsCreate(w http.ResponseWriter, r *http.Request) {
+	var req createCommandReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return
+	}
+	if req.Action == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "action required")
+		return
+	}
+
+	setAuditResource(r, "device", r.PathValue("id"))
+	c, err := s.app.CreateCommand(r.Context(), app.CommandRequest{
+		DeviceID: r.PathValue("id"),
+		Action:   req.Action,
+		Params:   req.Params,
+	})
+	if c.ID != "" {
+		setAuditResource(r, "command", c.ID)
+	}
+	if err != nil {
+		if errors.Is(err, app.ErrPublish) {
+			// команда уже сохранена (failed) — отдаём её id для диагностики
+			writeError(w, http.StatusServiceUnavailable, "publish_failed", "command "+c.ID+": "+err.Error())
+			return
+		}
+		writeCommandError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, toCommandDTO(c))
+}
+
+func (s *Server) handleCommandsGet(w http.ResponseWriter, r *http.Request) {
+	c, err := s.app.Commands.Get(r.Context(), r.PathValue("commandId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "command not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toCommandDTO(c))
+}
+
+const (
+	commandsDefaultLimit = 50
+	commandsMaxLimit     = 500
+)
+
+// handleDeviceCommandsList — история команд устройства, новые первыми.
+// Фильтры: ?status=pending,acked (через запятую), ?from=&to= (RFC3339),
+// страница ?limit=&cursor=; курсор следующей — в заголовке X-Next-Cursor.
+func (s *Server) handleDeviceCommandsList(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	q := r.URL.Query()
+	lq := storage.CommandListQuery{
+		DeviceID: id,
+		Cursor:   q.Get("cursor"),
+		Limit:    commandsDefaultLimit,
+	}
+
+	if v := q.Get("status"); v != "" {
+		for _, st := range strings.Split(v, ",") {
+			switch st {
+			case storage.CommandPending, storage.CommandAcked, storage.CommandFailed, storage.CommandTimeout:
+				lq.Statuses = append(lq.Statuses, st)
+			default:
+				writeError(w, http.StatusBadRequest, "bad_request", "status must be pending, acked, failed or timeout")
+				return
+			}
+		}
+	}
+	var err error
+	if lq.From, err = parseTimeParam(q.Get("from")); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "from must be RFC3339")
+		return
+	}
+	if lq.To, err = parseTimeParam(q.Get("to")); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "to must be RFC3339")
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		if lq.Limit, err = strconv.Atoi(v); err != nil || lq.Limit <= 0 || lq.Limit > commandsMaxLimit {
+			writeError(w, http.StatusBadRequest, "bad_request", "limit must be 1.."+strconv.Itoa(commandsMaxLimit))
+			return
+		}
+	}
+
+	if _, err := s.app.Devices.Get(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "device not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	page, err := s.app.Commands.ListByDevice(r.Context(), lq)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid cursor")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	out := make([]commandDTO, 0, len(page.Items))
+	for _, c := range page.Items {
+		out = append(out, toCommandDTO(c))
+	}
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// writeCommandError переводит ошибки app.CreateCommand в HTTP-ответ.
+func writeCommandError(w http.ResponseWriter, err error) {
+	code, msg := commandErrorCode(err)
+	writeError(w, code, msg, err.Error())
+}
+
+func commandErrorCode(err error) (int, string) {
+	switch {
+	case errors.Is(err, app.ErrDeviceNotFound):
+		return http.StatusNotFound, "not_found"
+	case errors.Is(err, app.ErrUnsupportedAction), errors.Is(err, app.ErrInvalidParams):
+		return http.StatusUnprocessableEntity, "invalid_command"
+	case errors.Is(err, app.ErrPublish):
+		return http.StatusServiceUnavailable, "publish_failed"
+	default:
+		return http.StatusInternalServerError, "internal"
+	}
+}
+
+func toCommandDTO(c storage.Command) commandDTO {
+	dto := commandDTO{
+		ID:        c.ID,
+		DeviceID:  c.DeviceID,
+		Action:    c.Action,
+		Params:    json.RawMessage(c.ParamsJSON),
+		Status:    c.Status,
+		Error:     c.Error,
+		CreatedAt: c.CreatedAt.UTC().Format(time.RFC3339Nano),
+
+		Source:         c.Source,
+		CorrelationID:  c.CorrelationID,
+		GroupCommandID: c.GroupCommandID,
+	}
+	if c.AckedAt != nil {
+		at := c.AckedAt.UTC().Format(time.RFC3339Nano)
+		dto.AckedAt = &at
+	}
+	return dto
+}
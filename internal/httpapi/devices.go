@@ -3,9 +3,14 @@ package httpapi
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/mqtt"
 	"github.com/ArthurGuatsaev/smarthome/internal/storage"
 )
 
@@ -14,6 +19,18 @@ type createDeviceReq struct {
 	Type         string   `json:"type"`
 	Capabilities []string `json:"capabilities"`
 	MQTTDeviceID string   `json:"mqttDeviceId"`
+	HomeID       string   `json:"homeId"` // пусто — дом по умолчанию
+	RoomID       string   `json:"roomId"`
+}
+
+// updateDeviceReq — PATCH: переданные поля заменяются, остальные остаются.
+type updateDeviceReq struct {
+	Name         *string   `json:"name"`
+	Type         *string   `json:"type"`
+	Capabilities *[]string `json:"capabilities"`
+	MQTTDeviceID *string   `json:"mqttDeviceId"`
+	HomeID       *string   `json:"homeId"`
+	RoomID       *string   `json:"roomId"` // "" — убрать из комнаты
 }
 
 type deviceDTO struct {
@@ -22,6 +39,10 @@ type deviceDTO struct {
 	Type         string   `json:"type"`
 	Capabilities []string `json:"capabilities"`
 	MQTTDeviceID string   `json:"mqttDeviceId"`
+	HomeID       string   `json:"homeId"`
+	RoomID       string   `json:"roomId,omitempty"`
+	Online       bool     `json:"online"`
+	LastSeenAt   *string  `json:"lastSeenAt"`
 	CreatedAt    string   `json:"createdAt"`
 }
 
@@ -31,8 +52,8 @@ func (s *Server) handleDevicesCreate(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
 		return
 	}
-	if req.Name == "" || req.Type == "" || req.MQTTDeviceID == "" {
-		writeError(w, http.StatusBadRequest, "bad_request", "name, type, mqttDeviceId required")
+	if req.Name == "" || req.Type == "" || !mqtt.ValidSegment(req.MQTTDeviceID) {
+		writeError(w, http.StatusBadRequest, "bad_request", "name, type and mqttDeviceId (without /, +, #) required")
 		return
 	}
 
@@ -44,29 +65,81 @@ func (s *Server) handleDevicesCreate(w http.ResponseWriter, r *http.Request) {
 		Type:         req.Type,
 		MQTTDeviceID: req.MQTTDeviceID,
 		Capabilities: string(capsJSON),
+		HomeID:       req.HomeID,
+		RoomID:       req.RoomID,
 		CreatedAt:    time.Now().UTC(),
 	}
+	setAuditResource(r, "device", d.ID)
+	deprecated := s.app.Capabilities.Deprecations(req.Type, req.Capabilities)
 
-	if err := s.app.Devices.Create(r.Context(), d); err != nil {
-		// уникальность mqtt_device_id
-		writeError(w, http.StatusConflict, "conflict", err.Error())
+	d, err := s.app.CreateDevice(r.Context(), d)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrConflict):
+			writeError(w, http.StatusConflict, "conflict", "mqttDeviceId already in use in this home")
+		case isPlacementError(err):
+			writeError(w, http.StatusUnprocessableEntity, "invalid_placement", err.Error())
+		case errors.Is(err, app.ErrInvalidCapabilities):
+			writeError(w, http.StatusUnprocessableEntity, "invalid_capabilities", err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		}
 		return
 	}
 
+	setDeprecationWarnings(w, deprecated)
 	writeJSON(w, http.StatusCreated, toDeviceDTO(d))
 }
 
+const (
+	devicesDefaultLimit = 100
+	devicesMaxLimit     = 500
+)
+
+// handleDevicesList: фильтры ?homeId=, ?roomId=, ?type=, ?capability=, ?namePrefix=,
+// сортировка ?sort=-createdAt|createdAt|name|-name, страница ?limit=&cursor=.
+// Курсор следующей страницы — в заголовке X-Next-Cursor (тело остаётся массивом).
 func (s *Server) handleDevicesList(w http.ResponseWriter, r *http.Request) {
-	items, err := s.app.Devices.List(r.Context())
+	q := r.URL.Query()
+	lq := storage.DeviceListQuery{
+		HomeID:     q.Get("homeId"),
+		RoomID:     q.Get("roomId"),
+		Type:       q.Get("type"),
+		Capability: q.Get("capability"),
+		NamePrefix: q.Get("namePrefix"),
+		Sort:       q.Get("sort"),
+		Cursor:     q.Get("cursor"),
+		Limit:      devicesDefaultLimit,
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > devicesMaxLimit {
+			writeError(w, http.StatusBadRequest, "bad_request", "limit must be 1.."+strconv.Itoa(devicesMaxLimit))
+			return
+		}
+		lq.Limit = n
+	}
+
+	page, err := s.app.Devices.List(r.Context(), lq)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		switch {
+		case errors.Is(err, storage.ErrInvalidSort):
+			writeError(w, http.StatusBadRequest, "bad_request", "sort must be one of -createdAt, createdAt, name, -name")
+		case errors.Is(err, storage.ErrInvalidCursor):
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid cursor")
+		default:
+			writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		}
 		return
 	}
 
-	out := make([]deviceDTO, 0, len(items))
-	for _, d := range items {
+	out := make([]deviceDTO, 0, len(page.Items))
+	for _, d := range page.Items {
 		out = append(out, toDeviceDTO(d))
 	}
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
 	writeJSON(w, http.StatusOK, out)
 }
 
@@ -85,9 +158,73 @@ func (s *Server) handleDevicesGet(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, toDeviceDTO(d))
 }
 
+func (s *Server) handleDevicesUpdate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	setAuditResource(r, "device", id)
+
+	var req updateDeviceReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return
+	}
+	if isBlank(req.Name) || isBlank(req.Type) || isBlank(req.HomeID) {
+		writeError(w, http.StatusBadRequest, "bad_request", "name, type, homeId must not be empty")
+		return
+	}
+	if req.MQTTDeviceID != nil && !mqtt.ValidSegment(*req.MQTTDeviceID) {
+		writeError(w, http.StatusBadRequest, "bad_request", "mqttDeviceId must not be empty or contain /, +, #")
+		return
+	}
+	if req.Capabilities != nil && *req.Capabilities == nil {
+		// "capabilities": null — то же, что пустой список
+		*req.Capabilities = []string{}
+	}
+
+	var deprecated []string
+	if req.Type != nil || req.Capabilities != nil {
+		var typ string
+		var caps []string
+		if req.Type != nil {
+			typ = *req.Type
+		}
+		if req.Capabilities != nil {
+			caps = *req.Capabilities
+		}
+		deprecated = s.app.Capabilities.Deprecations(typ, caps)
+	}
+
+	d, err := s.app.UpdateDevice(r.Context(), id, app.DevicePatch{
+		Name:         req.Name,
+		Type:         req.Type,
+		Capabilities: req.Capabilities,
+		MQTTDeviceID: req.MQTTDeviceID,
+		HomeID:       req.HomeID,
+		RoomID:       req.RoomID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, app.ErrDeviceNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "device not found")
+		case errors.Is(err, storage.ErrConflict):
+			writeError(w, http.StatusConflict, "conflict", "mqttDeviceId already in use in this home")
+		case isPlacementError(err):
+			writeError(w, http.StatusUnprocessableEntity, "invalid_placement", err.Error())
+		case errors.Is(err, app.ErrInvalidCapabilities):
+			writeError(w, http.StatusUnprocessableEntity, "invalid_capabilities", err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		}
+		return
+	}
+
+	setDeprecationWarnings(w, deprecated)
+	writeJSON(w, http.StatusOK, toDeviceDTO(d))
+}
+
 func (s *Server) handleDevicesDelete(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	if err := s.app.Devices.Delete(r.Context(), id); err != nil {
+	setAuditResource(r, "device", id)
+	if err := s.app.DeleteDevice(r.Context(), id); err != nil {
 		writeError(w, http.StatusInternalServerError, "internal", err.Error())
 		return
 	}
@@ -98,12 +235,40 @@ func toDeviceDTO(d storage.Device) deviceDTO {
 	var caps []string
 	_ = json.Unmarshal([]byte(d.Capabilities), &caps)
 
-	return deviceDTO{
+	dto := deviceDTO{
 		ID:           d.ID,
 		Name:         d.Name,
 		Type:         d.Type,
 		Capabilities: caps,
 		MQTTDeviceID: d.MQTTDeviceID,
+		HomeID:       d.HomeID,
+		RoomID:       d.RoomID,
+		Online:       d.Online,
 		CreatedAt:    d.CreatedAt.UTC().Format(time.RFC3339Nano),
 	}
+	if d.LastSeenAt != nil {
+		at := d.LastSeenAt.UTC().Format(time.RFC3339Nano)
+		dto.LastSeenAt = &at
+	}
+	return dto
+}
+
+func isPlacementError(err error) bool {
+	return errors.Is(err, app.ErrHomeNotFound) || errors.Is(err, app.ErrRoomNotFound) || errors.Is(err, app.ErrRoomNotInHome)
+}
+
+func isBlank(v *string) bool {
+	return v != nil && strings.TrimSpace(*v) == ""
+}
+
+// setDeprecationWarnings помечает ответ на запрос в устаревшем формате
+// (имена действий в capabilities, незарегистрированный тип): Deprecation + Warning 299.
+func setDeprecationWarnings(w http.ResponseWriter, msgs []string) {
+	if len(msgs) == 0 {
+		return
+	}
+	w.Header().Set("Deprecation", "true")
+	for _, m := range msgs {
+		w.Header().Add("Warning", `299 - "`+m+`"`)
+	}
 }
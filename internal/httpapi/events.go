@@ -0,0 +1,129 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+)
+
+// Интервал комментариев-пингов: держат соединение через прокси
+const sseHeartbeat = 15 * time.Second
+
+// handleEvents — поток событий в формате Server-Sent Events.
+//
+// Фильтры: ?deviceId=a,b и ?type=device.state_changed,command.ack
+// (можно повторять параметр). Resume: заголовок Last-Event-ID или ?lastEventId=.
+// Если часть событий уже вытеснена из буфера, первым приходит "event: resync" —
+// клиенту нужно перечитать состояние через REST.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	filter := eventFilter(queryList(r, "deviceId"), queryList(r, "type"))
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+
+	rc := http.NewResponseController(w)
+	// поток живёт дольше http.Server.WriteTimeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "streaming unsupported")
+		return
+	}
+
+	var (
+		sub      *app.Subscription
+		missed   []app.Event
+		complete = true
+	)
+	if id, err := strconv.ParseUint(lastID, 10, 64); err == nil {
+		sub, missed, complete = s.app.Events.SubscribeSince(id, 0, filter)
+	} else {
+		sub = s.app.Events.Subscribe(0, filter)
+	}
+	defer sub.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !complete {
+		fmt.Fprint(w, "event: resync\ndata: {}\n\n")
+	}
+	for _, e := range missed {
+		writeSSE(w, e)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ping := time.NewTicker(sseHeartbeat)
+	defer ping.Stop()
+
+	var dropped uint64
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			// медленный клиент потерял события — просим перечитать состояние
+			if d := sub.Dropped(); d != dropped {
+				dropped = d
+				fmt.Fprint(w, "event: resync\ndata: {}\n\n")
+			}
+			writeSSE(w, e)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, e app.Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
+
+func eventFilter(deviceIDs, types []string) func(app.Event) bool {
+	if len(deviceIDs) == 0 && len(types) == 0 {
+		return nil
+	}
+	return func(e app.Event) bool {
+		if len(deviceIDs) > 0 && !slices.Contains(deviceIDs, e.DeviceID) {
+			return false
+		}
+		if len(types) > 0 && !slices.Contains(types, string(e.Type)) {
+			return false
+		}
+		return true
+	}
+}
+
+// queryList собирает значения параметра: ?k=a,b&k=c -> [a b c]
+func queryList(r *http.Request, key string) []string {
+	var out []string
+	for _, v := range r.URL.Query()[key] {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
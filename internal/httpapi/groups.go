@@ -0,0 +1,277 @@
+package httpapi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+type createGroupReq struct {
+	Name      string   `json:"name"`
+	DeviceIDs []string `json:"deviceIds"`
+}
+
+// updateGroupReq — PATCH: deviceIds заменяет состав группы целиком.
+type updateGroupReq struct {
+	Name      *string   `json:"name"`
+	DeviceIDs *[]string `json:"deviceIds"`
+}
+
+type groupDTO struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	DeviceIDs []string `json:"deviceIds"`
+	CreatedAt string   `json:"createdAt"`
+	UpdatedAt string   `json:"updatedAt"`
+}
+
+type groupMemberDTO struct {
+	DeviceID  string `json:"deviceId"`
+	CommandID string `json:"commandId,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+type groupCommandDTO struct {
+	ID        string           `json:"id"`
+	GroupID   string           `json:"groupId"`
+	Action    string           `json:"action"`
+	Params    json.RawMessage  `json:"params"`
+	Status    string           `json:"status"` // pending|acked|failed|partial
+	Counts    map[string]int   `json:"counts"`
+	Members   []groupMemberDTO `json:"members"`
+	CreatedAt string           `json:"createdAt"`
+}
+
+func (s *Server) handleGroupsList(w http.ResponseWriter, r *http.Request) {
+	items, err := s.app.Groups.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	out := make([]groupDTO, 0, len(items))
+	for _, g := range items {
+		out = append(out, toGroupDTO(g))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleGroupsCreate(w http.ResponseWriter, r *http.Request) {
+	var req createGroupReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "name required")
+		return
+	}
+	ids, ok := s.checkGroupDevices(w, r, req.DeviceIDs)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	g := storage.Group{
+		ID:        newID(),
+		Name:      req.Name,
+		DeviceIDs: ids,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	setAuditResource(r, "group", g.ID)
+
+	if err := s.app.Groups.Create(r.Context(), g); err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			writeError(w, http.StatusConflict, "conflict", "group name already in use")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, toGroupDTO(g))
+}
+
+func (s *Server) handleGroupsGet(w http.ResponseWriter, r *http.Request) {
+	g, err := s.app.Groups.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "group not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, toGroupDTO(g))
+}
+
+func (s *Server) handleGroupsUpdate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	setAuditResource(r, "group", id)
+
+	var req updateGroupReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return
+	}
+	if isBlank(req.Name) {
+		writeError(w, http.StatusBadRequest, "bad_request", "name must not be empty")
+		return
+	}
+
+	g, err := s.app.Groups.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "group not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if req.Name != nil {
+		g.Name = *req.Name
+	}
+	if req.DeviceIDs != nil {
+		ids, ok := s.checkGroupDevices(w, r, *req.DeviceIDs)
+		if !ok {
+			return
+		}
+		g.DeviceIDs = ids
+	}
+	g.UpdatedAt = time.Now().UTC()
+
+	updated, err := s.app.Groups.Update(r.Context(), g)
+	if err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			writeError(w, http.StatusConflict, "conflict", "group name already in use")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if !updated {
+		writeError(w, http.StatusNotFound, "not_found", "group not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, toGroupDTO(g))
+}
+
+func (s *Server) handleGroupsDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	setAuditResource(r, "group", id)
+	if _, err := s.app.Groups.Delete(r.Context(), id); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGroupCommandsCreate рассылает команду всем устройствам группы.
+// 202 даже при частичном отказе: статус каждого участника — в members.
+func (s *Server) handleGroupCommandsCreate(w http.ResponseWriter, r *http.Request) {
+	var req createCommandReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return
+	}
+	if req.Action == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "action required")
+		return
+	}
+
+	setAuditResource(r, "group", r.PathValue("id"))
+	st, err := s.app.CreateGroupCommand(r.Context(), r.PathValue("id"), app.CommandRequest{
+		Action:      req.Action,
+		Params:      req.Params,
+		DeviceLimit: s.deviceLimit(),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, app.ErrGroupNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "group not found")
+		case errors.Is(err, app.ErrGroupEmpty), errors.Is(err, app.ErrInvalidParams):
+			writeError(w, http.StatusUnprocessableEntity, "invalid_command", err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		}
+		return
+	}
+	setAuditResource(r, "group_command", st.ID)
+
+	writeJSON(w, http.StatusAccepted, toGroupCommandDTO(st))
+}
+
+func (s *Server) handleGroupCommandsGet(w http.ResponseWriter, r *http.Request) {
+	st, err := s.app.GroupCommandStatus(r.Context(), r.PathValue("commandId"))
+	if err != nil {
+		if errors.Is(err, app.ErrGroupCommandNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", "group command not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if st.GroupID != r.PathValue("id") {
+		writeError(w, http.StatusNotFound, "not_found", "group command not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, toGroupCommandDTO(st))
+}
+
+// checkGroupDevices убирает дубли и проверяет, что устройства существуют;
+// при ошибке уже ответил клиенту.
+func (s *Server) checkGroupDevices(w http.ResponseWriter, r *http.Request, ids []string) ([]string, bool) {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	for _, id := range ids {
+		if _, err := s.app.Devices.Get(r.Context(), id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusUnprocessableEntity, "invalid_group", "unknown device: "+id)
+				return nil, false
+			}
+			writeError(w, http.StatusInternalServerError, "internal", err.Error())
+			return nil, false
+		}
+	}
+	return ids, true
+}
+
+func toGroupDTO(g storage.Group) groupDTO {
+	ids := g.DeviceIDs
+	if ids == nil {
+		ids = []string{}
+	}
+	return groupDTO{
+		ID:        g.ID,
+		Name:      g.Name,
+		DeviceIDs: ids,
+		CreatedAt: g.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt: g.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+func toGroupCommandDTO(st app.GroupCommandStatus) groupCommandDTO {
+	members := make([]groupMemberDTO, 0, len(st.Members))
+	for _, m := range st.Members {
+		members = append(members, groupMemberDTO(m))
+	}
+	return groupCommandDTO{
+		ID:        st.ID,
+		GroupID:   st.GroupID,
+		Action:    st.Action,
+		Params:    json.RawMessage(st.ParamsJSON),
+		Status:    st.Status,
+		Counts:    st.Counts,
+		Members:   members,
+		CreatedAt: st.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
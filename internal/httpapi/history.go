@@ -0,0 +1,143 @@
+package httpapi
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	historyDefaultRange = 24 * time.Hour
+	// ограничение ответа: сырых точек и интервалов агрегации
+	historyMaxPoints = 10000
+)
+
+// historyField — имя поля состояния или путь через точку (sensor.temp).
+var historyField = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+type historyPointDTO struct {
+	At    string  `json:"at"`
+	Value float64 `json:"value"`
+}
+
+type historyBucketDTO struct {
+	At    string  `json:"at"` // начало интервала
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	Count int     `json:"count"`
+}
+
+// historyDTO: без step — сырые точки (points), со step — агрегаты (buckets).
+type historyDTO struct {
+	DeviceID  string             `json:"deviceId"`
+	Field     string             `json:"field"`
+	From      string             `json:"from"`
+	To        string             `json:"to"`
+	Step      string             `json:"step,omitempty"`
+	Points    []historyPointDTO  `json:"points,omitzero"`
+	Buckets   []historyBucketDTO `json:"buckets,omitzero"`
+	Truncated bool               `json:"truncated,omitempty"` // точек больше historyMaxPoints
+}
+
+// handleDeviceHistory: ?field= (обязательно), ?from=&to= (RFC3339, по умолчанию
+// последние сутки), ?step= (Go duration, >= 1s) — агрегировать min/max/avg.
+func (s *Server) handleDeviceHistory(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	q := r.URL.Query()
+
+	field := q.Get("field")
+	if !historyField.MatchString(field) {
+		writeError(w, http.StatusBadRequest, "bad_request", "field required (name or dotted path)")
+		return
+	}
+	from, err := parseTimeParam(q.Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "from must be RFC3339")
+		return
+	}
+	to, err := parseTimeParam(q.Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "to must be RFC3339")
+		return
+	}
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+	if from.IsZero() {
+		from = to.Add(-historyDefaultRange)
+	}
+	if !from.Before(to) {
+		writeError(w, http.StatusBadRequest, "bad_request", "from must be before to")
+		return
+	}
+
+	var step time.Duration
+	if v := q.Get("step"); v != "" {
+		step, err = time.ParseDuration(v)
+		if err != nil || step < time.Second {
+			writeError(w, http.StatusBadRequest, "bad_request", "step must be a duration >= 1s")
+			return
+		}
+		step = step.Truncate(time.Second)
+		if to.Sub(from)/step > historyMaxPoints {
+			writeError(w, http.StatusBadRequest, "bad_request", "too many buckets, max "+strconv.Itoa(historyMaxPoints))
+			return
+		}
+	}
+
+	if _, err := s.app.Devices.Get(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "device not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	out := historyDTO{
+		DeviceID: id,
+		Field:    field,
+		From:     from.UTC().Format(time.RFC3339Nano),
+		To:       to.UTC().Format(time.RFC3339Nano),
+	}
+
+	if step == 0 {
+		points, err := s.app.History.Points(r.Context(), id, field, from, to, historyMaxPoints+1)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal", err.Error())
+			return
+		}
+		if len(points) > historyMaxPoints {
+			points = points[:historyMaxPoints]
+			out.Truncated = true
+		}
+		out.Points = make([]historyPointDTO, 0, len(points))
+		for _, p := range points {
+			out.Points = append(out.Points, historyPointDTO{At: p.At.UTC().Format(time.RFC3339Nano), Value: p.Value})
+		}
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
+
+	buckets, err := s.app.History.Buckets(r.Context(), id, field, from, to, step)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	out.Step = step.String()
+	out.Buckets = make([]historyBucketDTO, 0, len(buckets))
+	for _, b := range buckets {
+		out.Buckets = append(out.Buckets, historyBucketDTO{
+			At:    b.Start.Format(time.RFC3339),
+			Min:   b.Min,
+			Max:   b.Max,
+			Avg:   b.Avg,
+			Count: b.Count,
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,306 @@
+package httpapi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/mqtt"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+type createHomeReq struct {
+	Name       string `json:"name"`
+	MQTTHomeID string `json:"mqttHomeId"`
+}
+
+// updateHomeReq — PATCH: переданные поля заменяются, остальные остаются.
+type updateHomeReq struct {
+	Name       *string `json:"name"`
+	MQTTHomeID *string `json:"mqttHomeId"`
+}
+
+type homeDTO struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	MQTTHomeID string `json:"mqttHomeId"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+type roomReq struct {
+	Name string `json:"name"`
+}
+
+type roomDTO struct {
+	ID        string `json:"id"`
+	HomeID    string `json:"homeId"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"createdAt"`
+}
+
+func (s *Server) handleHomesList(w http.ResponseWriter, r *http.Request) {
+	items, err := s.app.Homes.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	out := make([]homeDTO, 0, len(items))
+	for _, h := range items {
+		out = append(out, toHomeDTO(h))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleHomesCreate(w http.ResponseWriter, r *http.Request) {
+	var req createHomeReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return
+	}
+	if req.Name == "" || !mqtt.ValidSegment(req.MQTTHomeID) {
+		writeError(w, http.StatusBadRequest, "bad_request", "name and mqttHomeId (without /, +, #) required")
+		return
+	}
+
+	h := storage.Home{
+		ID:         newID(),
+		Name:       req.Name,
+		MQTTHomeID: req.MQTTHomeID,
+		CreatedAt:  time.Now().UTC(),
+	}
+	setAuditResource(r, "home", h.ID)
+
+	if err := s.app.Homes.Create(r.Context(), h); err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			writeError(w, http.StatusConflict, "conflict", "mqttHomeId already in use")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, toHomeDTO(h))
+}
+
+func (s *Server) handleHomesGet(w http.ResponseWriter, r *http.Request) {
+	h, err := s.app.Homes.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "home not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, toHomeDTO(h))
+}
+
+// handleHomesUpdate: смена mqttHomeId меняет топики всех устройств дома.
+func (s *Server) handleHomesUpdate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	setAuditResource(r, "home", id)
+
+	var req updateHomeReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return
+	}
+	if isBlank(req.Name) || (req.MQTTHomeID != nil && !mqtt.ValidSegment(*req.MQTTHomeID)) {
+		writeError(w, http.StatusBadRequest, "bad_request", "name must not be empty, mqttHomeId must not contain /, +, #")
+		return
+	}
+
+	h, err := s.app.Homes.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "home not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if req.Name != nil {
+		h.Name = *req.Name
+	}
+	if req.MQTTHomeID != nil {
+		h.MQTTHomeID = *req.MQTTHomeID
+	}
+
+	updated, err := s.app.Homes.Update(r.Context(), h)
+	if err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			writeError(w, http.StatusConflict, "conflict", "mqttHomeId already in use")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if !updated {
+		writeError(w, http.StatusNotFound, "not_found", "home not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, toHomeDTO(h))
+}
+
+// handleHomesDelete удаляет дом с комнатами; дом с устройствами и дом по умолчанию — 409.
+func (s *Server) handleHomesDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	setAuditResource(r, "home", id)
+	if err := s.app.DeleteHome(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, app.ErrHomeNotEmpty), errors.Is(err, app.ErrDefaultHome):
+			writeError(w, http.StatusConflict, "conflict", err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleRoomsList(w http.ResponseWriter, r *http.Request) {
+	homeID := r.PathValue("id")
+	if _, err := s.app.Homes.Get(r.Context(), homeID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "home not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	items, err := s.app.Rooms.ListByHome(r.Context(), homeID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	out := make([]roomDTO, 0, len(items))
+	for _, rm := range items {
+		out = append(out, toRoomDTO(rm))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleRoomsCreate(w http.ResponseWriter, r *http.Request) {
+	homeID := r.PathValue("id")
+
+	var req roomReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "name required")
+		return
+	}
+
+	if _, err := s.app.Homes.Get(r.Context(), homeID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "home not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	rm := storage.Room{
+		ID:        newID(),
+		HomeID:    homeID,
+		Name:      req.Name,
+		CreatedAt: time.Now().UTC(),
+	}
+	setAuditResource(r, "room", rm.ID)
+
+	if err := s.app.Rooms.Create(r.Context(), rm); err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			writeError(w, http.StatusConflict, "conflict", "room name already in use in this home")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, toRoomDTO(rm))
+}
+
+func (s *Server) handleRoomsGet(w http.ResponseWriter, r *http.Request) {
+	rm, err := s.app.Rooms.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "room not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, toRoomDTO(rm))
+}
+
+// handleRoomsUpdate переименовывает комнату; перенос в другой дом не поддерживается.
+func (s *Server) handleRoomsUpdate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	setAuditResource(r, "room", id)
+
+	var req roomReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "name required")
+		return
+	}
+
+	updated, err := s.app.Rooms.Rename(r.Context(), id, req.Name)
+	if err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			writeError(w, http.StatusConflict, "conflict", "room name already in use in this home")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if !updated {
+		writeError(w, http.StatusNotFound, "not_found", "room not found")
+		return
+	}
+
+	rm, err := s.app.Rooms.Get(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, toRoomDTO(rm))
+}
+
+// handleRoomsDelete удаляет комнату; её устройства остаются в доме без комнаты.
+func (s *Server) handleRoomsDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	setAuditResource(r, "room", id)
+	if _, err := s.app.Rooms.Delete(r.Context(), id); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func toHomeDTO(h storage.Home) homeDTO {
+	return homeDTO{
+		ID:         h.ID,
+		Name:       h.Name,
+		MQTTHomeID: h.MQTTHomeID,
+		CreatedAt:  h.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+func toRoomDTO(rm storage.Room) roomDTO {
+	return roomDTO{
+		ID:        rm.ID,
+		HomeID:    rm.HomeID,
+		Name:      rm.Name,
+		CreatedAt: rm.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
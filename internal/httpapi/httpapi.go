@@ -7,36 +7,141 @@ import (
 
 	"github.com/ArthurGuatsaev/smarthome/internal/app"
 	"github.com/ArthurGuatsaev/smarthome/internal/buildinfo"
+	"github.com/ArthurGuatsaev/smarthome/internal/metrics"
+	"github.com/ArthurGuatsaev/smarthome/internal/schedule"
 )
 
 type Server struct {
-	mux    *http.ServeMux
-	ready  *ReadyState
-	app    *app.App
-	apiKey string
+	mux   *http.ServeMux
+	ready *ReadyState
+	app   *app.App
+
+	keyLimiter     *limiter
+	deviceLimiter  *limiter
+	planner        schedule.Planner
+	idempotencyTTL time.Duration
 }
 
-type ReadyState struct {
-	// пока просто флаг, позже сюда подключишь проверки БД/MQTT
-	isReady bool
+type Config struct {
+	// Лимиты на отправку команд: на API-ключ и на устройство
+	KeyRateLimit    RateLimit
+	DeviceRateLimit RateLimit
+
+	// Часовой пояс и координаты для расписаний
+	Planner schedule.Planner
+
+	// Сколько хранить ответы на POST с Idempotency-Key (0 — не поддерживать)
+	IdempotencyTTL time.Duration
 }
 
-func NewServer(a *app.App, apiKey string) *Server {
-	rs := &ReadyState{isReady: true}
+func NewServer(a *app.App, cfg Config) *Server {
+	rs := &ReadyState{}
 	mux := http.NewServeMux()
 
-	s := &Server{mux: mux, ready: rs, app: a, apiKey: apiKey}
+	s := &Server{
+		mux:            mux,
+		ready:          rs,
+		app:            a,
+		keyLimiter:     newLimiter(cfg.KeyRateLimit),
+		deviceLimiter:  newLimiter(cfg.DeviceRateLimit),
+		planner:        cfg.Planner,
+		idempotencyTTL: cfg.IdempotencyTTL,
+	}
+
+	read := func(h http.HandlerFunc) http.Handler { return s.scoped(app.ScopeDevicesRead, h) }
+	send := func(h http.HandlerFunc) http.Handler { return s.scoped(app.ScopeCommandsSend, s.rateLimited(h)) }
+	admin := func(h http.HandlerFunc) http.Handler { return s.scoped(app.ScopeAdmin, h) }
 
 	// system
 	mux.HandleFunc("GET /healthz", s.handleHealthz)
 	mux.HandleFunc("GET /readyz", s.handleReadyz)
 	mux.HandleFunc("GET /api/v1/version", s.handleVersion)
+	mux.Handle("GET /metrics", metrics.Handler())
+
+	// homes & rooms
+	mux.Handle("GET /api/v1/homes", read(s.handleHomesList))
+	mux.Handle("POST /api/v1/homes", admin(s.handleHomesCreate))
+	mux.Handle("GET /api/v1/homes/{id}", read(s.handleHomesGet))
+	mux.Handle("PATCH /api/v1/homes/{id}", admin(s.handleHomesUpdate))
+	mux.Handle("DELETE /api/v1/homes/{id}", admin(s.handleHomesDelete))
+	mux.Handle("GET /api/v1/homes/{id}/rooms", read(s.handleRoomsList))
+	mux.Handle("POST /api/v1/homes/{id}/rooms", admin(s.handleRoomsCreate))
+	mux.Handle("GET /api/v1/rooms/{id}", read(s.handleRoomsGet))
+	mux.Handle("PATCH /api/v1/rooms/{id}", admin(s.handleRoomsUpdate))
+	mux.Handle("DELETE /api/v1/rooms/{id}", admin(s.handleRoomsDelete))
+
+	// capabilities
+	mux.Handle("GET /api/v1/capabilities", read(s.handleCapabilitiesList))
 
 	// devices
-	mux.HandleFunc("GET /api/v1/devices", s.handleDevicesList)
-	mux.HandleFunc("POST /api/v1/devices", s.handleDevicesCreate)
-	mux.HandleFunc("GET /api/v1/devices/{id}", s.handleDevicesGet)
-	mux.HandleFunc("DELETE /api/v1/devices/{id}", s.handleDevicesDelete)
+	mux.Handle("GET /api/v1/devices", read(s.handleDevicesList))
+	mux.Handle("POST /api/v1/devices", admin(s.handleDevicesCreate))
+	mux.Handle("GET /api/v1/devices/{id}", read(s.handleDevicesGet))
+	mux.Handle("PATCH /api/v1/devices/{id}", admin(s.handleDevicesUpdate))
+	mux.Handle("DELETE /api/v1/devices/{id}", admin(s.handleDevicesDelete))
+	mux.Handle("GET /api/v1/devices/{id}/state", read(s.handleDeviceStateGet))
+	mux.Handle("GET /api/v1/devices/{id}/history", read(s.handleDeviceHistory))
+
+	// commands
+	mux.Handle("POST /api/v1/devices/{id}/commands", send(s.handleCommandsCreate))
+	mux.Handle("GET /api/v1/devices/{id}/commands", read(s.handleDeviceCommandsList))
+	mux.Handle("GET /api/v1/commands/{commandId}", read(s.handleCommandsGet))
+
+	// groups
+	mux.Handle("GET /api/v1/groups", read(s.handleGroupsList))
+	mux.Handle("POST /api/v1/groups", admin(s.handleGroupsCreate))
+	mux.Handle("GET /api/v1/groups/{id}", read(s.handleGroupsGet))
+	mux.Handle("PATCH /api/v1/groups/{id}", admin(s.handleGroupsUpdate))
+	mux.Handle("DELETE /api/v1/groups/{id}", admin(s.handleGroupsDelete))
+	mux.Handle("POST /api/v1/groups/{id}/commands", s.scoped(app.ScopeCommandsSend, s.keyRateLimited(s.handleGroupCommandsCreate)))
+	mux.Handle("GET /api/v1/groups/{id}/commands/{commandId}", read(s.handleGroupCommandsGet))
+
+	// scenes
+	mux.Handle("GET /api/v1/scenes", read(s.handleScenesList))
+	mux.Handle("POST /api/v1/scenes", admin(s.handleScenesCreate))
+	mux.Handle("GET /api/v1/scenes/{id}", read(s.handleScenesGet))
+	mux.Handle("PUT /api/v1/scenes/{id}", admin(s.handleScenesUpdate))
+	mux.Handle("DELETE /api/v1/scenes/{id}", admin(s.handleScenesDelete))
+	mux.Handle("POST /api/v1/scenes/{id}/activate", s.scoped(app.ScopeCommandsSend, s.keyRateLimited(s.handleScenesActivate)))
+	mux.Handle("GET /api/v1/scenes/{id}/runs", read(s.handleSceneRunsList))
+	mux.Handle("GET /api/v1/scenes/{id}/runs/{runId}", read(s.handleSceneRunsGet))
+
+	// schedules
+	mux.Handle("GET /api/v1/schedules", read(s.handleSchedulesList))
+	mux.Handle("POST /api/v1/schedules", admin(s.handleSchedulesCreate))
+	mux.Handle("GET /api/v1/schedules/{id}", read(s.handleSchedulesGet))
+	mux.Handle("PUT /api/v1/schedules/{id}", admin(s.handleSchedulesUpdate))
+	mux.Handle("DELETE /api/v1/schedules/{id}", admin(s.handleSchedulesDelete))
+	mux.Handle("GET /api/v1/schedules/{id}/next", read(s.handleSchedulesNext))
+
+	// webhooks
+	mux.Handle("GET /api/v1/webhooks", admin(s.handleWebhooksList))
+	mux.Handle("POST /api/v1/webhooks", admin(s.handleWebhooksCreate))
+	mux.Handle("GET /api/v1/webhooks/dead-letters", admin(s.handleWebhookDeadLetters))
+	mux.Handle("GET /api/v1/webhooks/{id}", admin(s.handleWebhooksGet))
+	mux.Handle("PATCH /api/v1/webhooks/{id}", admin(s.handleWebhooksUpdate))
+	mux.Handle("DELETE /api/v1/webhooks/{id}", admin(s.handleWebhooksDelete))
+	mux.Handle("GET /api/v1/webhooks/{id}/deliveries", admin(s.handleWebhookDeliveries))
+	mux.Handle("POST /api/v1/webhooks/{id}/deliveries/{deliveryId}/retry", admin(s.handleWebhookDeliveryRetry))
+
+	// rules
+	mux.Handle("GET /api/v1/rules", read(s.handleRulesList))
+	mux.Handle("POST /api/v1/rules", admin(s.handleRulesCreate))
+	mux.Handle("GET /api/v1/rules/{id}", read(s.handleRulesGet))
+	mux.Handle("PUT /api/v1/rules/{id}", admin(s.handleRulesUpdate))
+	mux.Handle("DELETE /api/v1/rules/{id}", admin(s.handleRulesDelete))
+
+	// events (команды через ws дополнительно требуют commands:send)
+	mux.Handle("GET /api/v1/events", read(s.handleEvents))
+	mux.Handle("GET /api/v1/ws", read(s.handleWS))
+
+	// api keys
+	mux.Handle("GET /api/v1/keys", admin(s.handleKeysList))
+	mux.Handle("POST /api/v1/keys", admin(s.handleKeysCreate))
+	mux.Handle("DELETE /api/v1/keys/{id}", admin(s.handleKeysRevoke))
+
+	// audit
+	mux.Handle("GET /api/v1/audit", admin(s.handleAuditList))
 
 	return s
 }
@@ -46,9 +151,13 @@ func (s *Server) Handler() http.Handler {
 	return Chain(s.mux,
 		RequestID(),
 		AccessLog(),
+		Metrics(s.routePattern),
+		Audit(s.app.Audit, s.routePattern),
 		Recoverer(),
-		Timeout(8*time.Second),
-		RequireAPIKey(s.apiKey),
+		// долгоживущие потоки не должны обрываться по таймауту
+		Timeout(8*time.Second, "/api/v1/events", "/api/v1/ws"),
+		RequireAPIKey(s.app),
+		Idempotency(s.app.Idempotency, s.idempotencyTTL),
 	)
 }
 
@@ -57,15 +166,6 @@ func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("ok"))
 }
 
-func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
-	if !s.ready.isReady {
-		http.Error(w, "not ready", http.StatusServiceUnavailable)
-		return
-	}
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("ready"))
-}
-
 func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]string{
@@ -74,7 +174,3 @@ func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
 		"date":    buildinfo.Date,
 	})
 }
-
-func (s *Server) SetReady(v bool) {
-	s.ready.isReady = v
-}
@@ -0,0 +1,184 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	idempotencyMaxKeyLen    = 255
+	idempotencyMaxBody      = 1 << 20
+	idempotencyWriteTimeout = 2 * time.Second
+
+	// Незавершённый ключ старше этого считается брошенным (процесс упал посреди запроса)
+	idempotencyPendingLease = time.Minute
+)
+
+// Idempotency делает POST с заголовком Idempotency-Key повторяемым: ответ первого
+// запроса хранится ttl и отдаётся на повторы с тем же ключом (Idempotent-Replayed: true).
+// Ключ действует в пределах API-ключа; тот же ключ с другим телом или путём — 422,
+// пока первый запрос выполняется — 409. 5xx и 429 не сохраняются: повтор выполнится заново.
+// ttl <= 0 — заголовок игнорируется.
+func Idempotency(repo *storage.IdempotencyRepo, ttl time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		if ttl <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotencyKeyHeader)
+			p, ok := PrincipalFrom(r.Context())
+			if r.Method != http.MethodPost || key == "" || !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > idempotencyMaxKeyLen {
+				writeError(w, http.StatusBadRequest, "bad_request", "Idempotency-Key is too long")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, idempotencyMaxBody))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					writeError(w, http.StatusRequestEntityTooLarge, "payload_too_large", "request body is too large")
+					return
+				}
+				writeError(w, http.StatusBadRequest, "bad_request", "cannot read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			now := time.Now().UTC()
+			rec := storage.IdempotencyRecord{
+				Principal:   idempotencyPrincipal(p),
+				Key:         key,
+				RequestHash: idempotencyHash(r, body),
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(ttl),
+			}
+			existing, reserved, err := repo.Reserve(r.Context(), rec, now.Add(-idempotencyPendingLease))
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "internal", err.Error())
+				return
+			}
+			if !reserved {
+				switch {
+				case existing.RequestHash != rec.RequestHash:
+					writeError(w, http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency-Key was already used with a different request")
+				case existing.StatusCode == 0:
+					writeError(w, http.StatusConflict, "conflict", "request with this Idempotency-Key is still in progress")
+				default:
+					replayResponse(w, existing)
+				}
+				return
+			}
+
+			// запись в БД не должна зависеть от отмены запроса: операция уже выполнена
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), idempotencyWriteTimeout)
+			defer cancel()
+
+			stored := false
+			defer func() {
+				// ответ не сохранён (5xx, 429, паника) — освобождаем ключ для повтора
+				if !stored {
+					if err := repo.Release(ctx, rec.Principal, rec.Key); err != nil {
+						slog.Error("idempotency_release_error", "err", err)
+					}
+				}
+			}()
+
+			rw := &recordingWriter{ResponseWriter: w, header: http.Header{}, status: http.StatusOK}
+			next.ServeHTTP(rw, r)
+
+			if rw.status >= 500 || rw.status == http.StatusTooManyRequests {
+				return
+			}
+			headers, _ := json.Marshal(rw.header)
+			rec.StatusCode = rw.status
+			rec.HeadersJSON = string(headers)
+			rec.Body = rw.body.Bytes()
+			if err := repo.Complete(ctx, rec); err != nil {
+				slog.Error("idempotency_store_error", "err", err)
+				return
+			}
+			stored = true
+		})
+	}
+}
+
+// idempotencyPrincipal — пространство ключей: id API-ключа, у bootstrap-ключа id нет.
+func idempotencyPrincipal(p app.Principal) string {
+	if p.KeyID != "" {
+		return p.KeyID
+	}
+	return p.Name
+}
+
+// idempotencyHash привязывает ключ к конкретному запросу: метод, путь с query и тело.
+func idempotencyHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replayResponse(w http.ResponseWriter, rec storage.IdempotencyRecord) {
+	var headers http.Header
+	_ = json.Unmarshal([]byte(rec.HeadersJSON), &headers)
+	for k, v := range headers {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(rec.StatusCode)
+	_, _ = w.Write(rec.Body)
+}
+
+// recordingWriter пишет ответ клиенту и запоминает его для повторов.
+// Заголовки собираются отдельно, чтобы не сохранять выставленные внешними middleware.
+type recordingWriter struct {
+	http.ResponseWriter
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (w *recordingWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *recordingWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	for k, v := range w.header {
+		w.ResponseWriter.Header()[k] = v
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
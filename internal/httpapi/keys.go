@@ -0,0 +1,116 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+type createKeyReq struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type apiKeyDTO struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	Key       string   `json:"key,omitempty"` // только в ответе на создание
+	CreatedAt string   `json:"createdAt"`
+	RevokedAt *string  `json:"revokedAt"`
+}
+
+// scoped пропускает запрос, только если у ключа есть право scope.
+func (s *Server) scoped(scope string, h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := PrincipalFrom(r.Context())
+		if !ok || !p.Has(scope) {
+			writeError(w, http.StatusForbidden, "forbidden", "api key lacks scope "+scope)
+			return
+		}
+		h(w, r)
+	})
+}
+
+func (s *Server) handleKeysList(w http.ResponseWriter, r *http.Request) {
+	items, err := s.app.APIKeys.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	out := make([]apiKeyDTO, 0, len(items))
+	for _, k := range items {
+		out = append(out, toAPIKeyDTO(k))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleKeysCreate(w http.ResponseWriter, r *http.Request) {
+	var req createKeyReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "name required")
+		return
+	}
+
+	k, plain, err := s.app.MintAPIKey(r.Context(), req.Name, req.Scopes)
+	if err != nil {
+		if errors.Is(err, app.ErrInvalidScopes) {
+			writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+		if errors.Is(err, app.ErrReservedKeyName) {
+			writeError(w, http.StatusUnprocessableEntity, "reserved_name", err.Error())
+			return
+		}
+		// уникальность name
+		writeError(w, http.StatusConflict, "conflict", err.Error())
+		return
+	}
+
+	setAuditResource(r, "api_key", k.ID)
+	dto := toAPIKeyDTO(k)
+	dto.Key = plain
+	writeJSON(w, http.StatusCreated, dto)
+}
+
+func (s *Server) handleKeysRevoke(w http.ResponseWriter, r *http.Request) {
+	setAuditResource(r, "api_key", r.PathValue("id"))
+	ok, err := s.app.APIKeys.Revoke(r.Context(), r.PathValue("id"), time.Now().UTC())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "active key not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func toAPIKeyDTO(k storage.APIKey) apiKeyDTO {
+	var scopes []string
+	_ = json.Unmarshal([]byte(k.ScopesJSON), &scopes)
+
+	dto := apiKeyDTO{
+		ID:        k.ID,
+		Name:      k.Name,
+		Scopes:    scopes,
+		CreatedAt: k.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	if k.RevokedAt != nil {
+		at := k.RevokedAt.UTC().Format(time.RFC3339Nano)
+		dto.RevokedAt = &at
+	}
+	return dto
+}
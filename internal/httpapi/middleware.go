@@ -1,13 +1,22 @@
 package httpapi
 
 import (
+	"bufio"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"log/slog"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/metrics"
 )
 
 type Middleware func(http.Handler) http.Handler
@@ -21,7 +30,23 @@ func Chain(h http.Handler, m ...Middleware) http.Handler {
 
 type ctxKey string
 
-const requestIDKey ctxKey = "req_id"
+const (
+	requestIDKey   ctxKey = "req_id"
+	requestInfoKey ctxKey = "req_info"
+	principalKey   ctxKey = "principal"
+)
+
+// requestInfo — изменяемые данные запроса, которые внутренние middleware
+// (аутентификация) сообщают внешним (access log).
+type requestInfo struct {
+	actor    string
+	resource string
+}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey).(*requestInfo)
+	return info
+}
 
 func RequestID() Middleware {
 	return func(next http.Handler) http.Handler {
@@ -42,6 +67,8 @@ func AccessLog() Middleware {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			ww := &wrapWriter{ResponseWriter: w, status: 200}
+			info := &requestInfo{}
+			r = r.WithContext(context.WithValue(r.Context(), requestInfoKey, info))
 
 			next.ServeHTTP(ww, r)
 
@@ -53,11 +80,33 @@ func AccessLog() Middleware {
 				"dur_ms", time.Since(start).Milliseconds(),
 				"req_id", reqID,
 				"remote", r.RemoteAddr,
+				"actor", info.actor,
 			)
 		})
 	}
 }
 
+// Metrics пишет длительность запросов в гистограмму по шаблону маршрута
+// (а не пути, чтобы id не раздували число серий).
+func Metrics(route func(*http.Request) string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := &wrapWriter{ResponseWriter: w, status: 200}
+
+			next.ServeHTTP(ww, r)
+
+			pattern := route(r)
+			if pattern == "" {
+				pattern = "unmatched"
+			}
+			metrics.HTTPRequestDuration.
+				WithLabelValues(r.Method, pattern, strconv.Itoa(ww.status)).
+				Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
 func Recoverer() Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -77,9 +126,17 @@ func Recoverer() Middleware {
 	}
 }
 
-func Timeout(d time.Duration) Middleware {
+// Timeout ограничивает время обработки запроса; пути из skip (стримы) пропускаются как есть.
+func Timeout(d time.Duration, skip ...string) Middleware {
 	return func(next http.Handler) http.Handler {
-		return http.TimeoutHandler(next, d, "timeout\n")
+		th := http.TimeoutHandler(next, d, "timeout\n")
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(skip, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			th.ServeHTTP(w, r)
+		})
 	}
 }
 
@@ -93,28 +150,69 @@ func (w *wrapWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+// Hijack нужен для WebSocket upgrade.
+func (w *wrapWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// Unwrap нужен http.ResponseController (Flush, SetWriteDeadline) для стримов.
+func (w *wrapWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func newReqID() string {
 	b := make([]byte, 16)
 	_, _ = rand.Read(b)
 	return hex.EncodeToString(b)
 }
 
-func RequireAPIKey(expected string) Middleware {
+// RequireAPIKey аутентифицирует запрос по API-ключу и кладёт app.Principal в контекст.
+// Права конкретных маршрутов проверяет Server.scoped.
+func RequireAPIKey(a *app.App) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Разрешим health/ready без ключа
-			if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			// Разрешим health/ready и метрики без ключа (скрейпер Prometheus)
+			if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || r.URL.Path == "/metrics" {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			got := r.Header.Get("X-API-Key")
-			if expected != "" && got != expected {
-				http.Error(w, "unauthorized", http.StatusUnauthorized)
+			p, err := a.Authenticate(r.Context(), requestAPIKey(r))
+			if err != nil {
+				if !errors.Is(err, app.ErrInvalidAPIKey) {
+					slog.Error("auth_error", "err", err)
+				}
+				writeError(w, http.StatusUnauthorized, "unauthorized", "invalid or missing api key")
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			if info := requestInfoFrom(r.Context()); info != nil {
+				info.actor = p.Name
+			}
+			ctx := context.WithValue(r.Context(), principalKey, p)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// PrincipalFrom возвращает владельца ключа текущего запроса.
+func PrincipalFrom(ctx context.Context) (app.Principal, bool) {
+	p, ok := ctx.Value(principalKey).(app.Principal)
+	return p, ok
+}
+
+// requestAPIKey берёт ключ из X-API-Key. Браузер не умеет ставить заголовки
+// на WebSocket-запрос, поэтому для upgrade допускается ?api_key=.
+func requestAPIKey(r *http.Request) string {
+	if k := r.Header.Get("X-API-Key"); k != "" {
+		return k
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return r.URL.Query().Get("api_key")
+	}
+	return ""
+}
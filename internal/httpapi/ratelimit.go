@@ -0,0 +1,153 @@
+package httpapi
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+)
+
+// RateLimit — параметры token bucket: RPS токенов в секунду, ёмкость Burst.
+// RPS <= 0 отключает ограничение.
+type RateLimit struct {
+	RPS   float64
+	Burst int
+}
+
+// Как часто (в вызовах take) чистить давно неиспользуемые корзины
+const limiterSweepEvery = 1024
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type limiter struct {
+	cfg RateLimit
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+}
+
+func newLimiter(cfg RateLimit) *limiter {
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+	return &limiter{cfg: cfg, buckets: map[string]*bucket{}}
+}
+
+func (l *limiter) enabled() bool { return l != nil && l.cfg.RPS > 0 }
+
+// take забирает токен из корзины key. При отказе retryAfter — когда появится токен.
+func (l *limiter) take(key string, now time.Time) (ok bool, remaining int, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	burst := float64(l.cfg.Burst)
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*l.cfg.RPS)
+	b.last = now
+
+	l.calls++
+	if l.calls%limiterSweepEvery == 0 {
+		l.sweep(now)
+	}
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.cfg.RPS * float64(time.Second))
+		return false, 0, wait
+	}
+	b.tokens--
+	return true, int(b.tokens), 0
+}
+
+// sweep удаляет корзины, которые успели полностью наполниться: они эквивалентны новым.
+func (l *limiter) sweep(now time.Time) {
+	full := time.Duration(float64(l.cfg.Burst) / l.cfg.RPS * float64(time.Second))
+	for k, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// rateLimited ограничивает отправку команд: отдельная корзина на API-ключ
+// и на целевое устройство (path value {id}). Лимит и остаток отдаются
+// в X-RateLimit-*; при превышении — 429 с Retry-After.
+func (s *Server) rateLimited(h http.HandlerFunc) http.HandlerFunc {
+	return s.limitCommands(h, func(r *http.Request) string { return r.PathValue("id") })
+}
+
+// keyRateLimited — только корзина API-ключа: групповая команда — один вызов.
+// Корзины устройств тратятся при fan-out, по команде на участника (deviceLimit).
+func (s *Server) keyRateLimited(h http.HandlerFunc) http.HandlerFunc {
+	return s.limitCommands(h, func(*http.Request) string { return "" })
+}
+
+// deviceLimit — корзина устройства для команд fan-out (те же корзины, что у
+// одиночных команд); nil, если лимит на устройство выключен.
+func (s *Server) deviceLimit() app.DeviceLimit {
+	if !s.deviceLimiter.enabled() {
+		return nil
+	}
+	return func(deviceID string) error {
+		if ok, _, retry := s.deviceLimiter.take("device:"+deviceID, time.Now()); !ok {
+			return fmt.Errorf("%w, retry after %s", app.ErrRateLimited, retry.Round(time.Millisecond))
+		}
+		return nil
+	}
+}
+
+func (s *Server) limitCommands(h http.HandlerFunc, deviceID func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFrom(r.Context())
+		if ok, retry := s.allowCommand(w, p.Name, deviceID(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, "rate_limited", "too many commands, retry after "+retry.Round(time.Millisecond).String())
+			return
+		}
+		h(w, r)
+	}
+}
+
+// allowCommand проверяет обе корзины и выставляет заголовки по самой строгой.
+// Токен ключа тратится, даже если отказала корзина устройства.
+func (s *Server) allowCommand(w http.ResponseWriter, actor, deviceID string) (bool, time.Duration) {
+	now := time.Now()
+	limit, remaining := -1, -1
+	var retry time.Duration
+	allowed := true
+
+	check := func(l *limiter, key string) {
+		if !l.enabled() || key == "" || !allowed {
+			return
+		}
+		ok, rem, wait := l.take(key, now)
+		if limit < 0 || rem < remaining {
+			limit, remaining = l.cfg.Burst, rem
+		}
+		if !ok {
+			allowed, retry = false, wait
+		}
+	}
+	check(s.keyLimiter, "key:"+actor)
+	if deviceID != "" {
+		check(s.deviceLimiter, "device:"+deviceID)
+	}
+
+	if w != nil && limit >= 0 {
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	}
+	return allowed, retry
+}
@@ -0,0 +1,105 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReadyCheck — проверка готовности зависимости; ошибка означает "не готов".
+type ReadyCheck func(ctx context.Context) error
+
+// Таймаут одной проверки /readyz
+const readyCheckTimeout = 2 * time.Second
+
+var errShuttingDown = errors.New("server is shutting down")
+
+// ReadyState — реестр проверок готовности плюс ручной флаг (SetReady).
+type ReadyState struct {
+	down atomic.Bool // выставляется при shutdown
+
+	mu     sync.RWMutex
+	checks []namedCheck
+}
+
+type namedCheck struct {
+	name  string
+	check ReadyCheck
+}
+
+type readyCheckDTO struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"` // ok|fail
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type readyDTO struct {
+	Status string          `json:"status"` // ready|not_ready
+	Checks []readyCheckDTO `json:"checks"`
+}
+
+// AddReadyCheck регистрирует проверку для /readyz.
+func (s *Server) AddReadyCheck(name string, c ReadyCheck) {
+	s.ready.mu.Lock()
+	defer s.ready.mu.Unlock()
+	s.ready.checks = append(s.ready.checks, namedCheck{name: name, check: c})
+}
+
+// SetReady вручную переключает готовность (false — например, на время shutdown).
+func (s *Server) SetReady(v bool) {
+	s.ready.down.Store(!v)
+}
+
+// run выполняет все проверки параллельно, каждую со своим таймаутом.
+func (rs *ReadyState) run(ctx context.Context) readyDTO {
+	rs.mu.RLock()
+	checks := append([]namedCheck(nil), rs.checks...)
+	rs.mu.RUnlock()
+
+	out := readyDTO{Status: "ready", Checks: make([]readyCheckDTO, len(checks))}
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Go(func() {
+			cctx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.check(cctx)
+			res := readyCheckDTO{
+				Name:      c.name,
+				Status:    "ok",
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				res.Status = "fail"
+				res.Error = err.Error()
+			}
+			out.Checks[i] = res
+		})
+	}
+	wg.Wait()
+
+	if rs.down.Load() {
+		out.Checks = append(out.Checks, readyCheckDTO{Name: "server", Status: "fail", Error: errShuttingDown.Error()})
+	}
+	for _, c := range out.Checks {
+		if c.Status != "ok" {
+			out.Status = "not_ready"
+		}
+	}
+	return out
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	res := s.ready.run(r.Context())
+	code := http.StatusOK
+	if res.Status != "ready" {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, res)
+}
@@ -0,0 +1,164 @@
+package httpapi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/rules"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+type ruleReq struct {
+	Name    string `json:"name"`
+	Enabled *bool  `json:"enabled"`
+	rules.Spec
+}
+
+type ruleDTO struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	rules.Spec
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+func (s *Server) handleRulesList(w http.ResponseWriter, r *http.Request) {
+	items, err := s.app.Rules.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	out := make([]ruleDTO, 0, len(items))
+	for _, ru := range items {
+		out = append(out, toRuleDTO(ru))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleRulesCreate(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeRuleReq(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	ru := storage.Rule{
+		ID:        newID(),
+		Name:      req.Name,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	req.Spec.Encode(&ru)
+	setAuditResource(r, "rule", ru.ID)
+
+	if err := s.app.Rules.Create(r.Context(), ru); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, toRuleDTO(ru))
+}
+
+func (s *Server) handleRulesGet(w http.ResponseWriter, r *http.Request) {
+	ru, err := s.app.Rules.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "rule not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, toRuleDTO(ru))
+}
+
+// handleRulesUpdate заменяет правило целиком (PUT).
+func (s *Server) handleRulesUpdate(w http.ResponseWriter, r *http.Request) {
+	setAuditResource(r, "rule", r.PathValue("id"))
+	cur, err := s.app.Rules.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "rule not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	req, ok := s.decodeRuleReq(w, r)
+	if !ok {
+		return
+	}
+
+	cur.Name = req.Name
+	cur.Enabled = req.Enabled == nil || *req.Enabled
+	cur.UpdatedAt = time.Now().UTC()
+	req.Spec.Encode(&cur)
+
+	updated, err := s.app.Rules.Update(r.Context(), cur)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if !updated {
+		writeError(w, http.StatusNotFound, "not_found", "rule not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, toRuleDTO(cur))
+}
+
+func (s *Server) handleRulesDelete(w http.ResponseWriter, r *http.Request) {
+	setAuditResource(r, "rule", r.PathValue("id"))
+	if _, err := s.app.Rules.Delete(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeRuleReq разбирает и валидирует тело правила; при ошибке уже ответил клиенту.
+func (s *Server) decodeRuleReq(w http.ResponseWriter, r *http.Request) (ruleReq, bool) {
+	var req ruleReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return ruleReq{}, false
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "name required")
+		return ruleReq{}, false
+	}
+	if err := req.Spec.Validate(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_rule", err.Error())
+		return ruleReq{}, false
+	}
+
+	for _, id := range req.Spec.DeviceIDs() {
+		if _, err := s.app.Devices.Get(r.Context(), id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusUnprocessableEntity, "invalid_rule", "unknown device: "+id)
+				return ruleReq{}, false
+			}
+			writeError(w, http.StatusInternalServerError, "internal", err.Error())
+			return ruleReq{}, false
+		}
+	}
+	return req, true
+}
+
+func toRuleDTO(ru storage.Rule) ruleDTO {
+	// в БД лежит только то, что прошло Validate
+	spec, _ := rules.ParseSpec(ru)
+	return ruleDTO{
+		ID:        ru.ID,
+		Name:      ru.Name,
+		Enabled:   ru.Enabled,
+		Spec:      spec,
+		CreatedAt: ru.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt: ru.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
@@ -0,0 +1,283 @@
+package httpapi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+const (
+	sceneRunsDefaultLimit = 20
+	sceneRunsMaxLimit     = 100
+)
+
+type sceneReq struct {
+	Name  string          `json:"name"`
+	Steps []app.SceneStep `json:"steps"`
+}
+
+type sceneDTO struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Steps     []app.SceneStep `json:"steps"`
+	CreatedAt string          `json:"createdAt"`
+	UpdatedAt string          `json:"updatedAt"`
+}
+
+type sceneRunStepDTO struct {
+	DeviceID     string  `json:"deviceId"`
+	Action       string  `json:"action"`
+	Delay        string  `json:"delay,omitempty"`
+	Status       string  `json:"status"` // scheduled|rejected|skipped|deleted или статус команды
+	CommandID    string  `json:"commandId,omitempty"`
+	Error        string  `json:"error,omitempty"`
+	DispatchedAt *string `json:"dispatchedAt"`
+}
+
+type sceneRunDTO struct {
+	ID         string            `json:"id"`
+	SceneID    string            `json:"sceneId"`
+	Source     string            `json:"source"`
+	Status     string            `json:"status"`  // running|completed|cancelled|interrupted
+	Outcome    string            `json:"outcome"` // pending|acked|failed|partial
+	Counts     map[string]int    `json:"counts"`
+	Steps      []sceneRunStepDTO `json:"steps"`
+	StartedAt  string            `json:"startedAt"`
+	FinishedAt *string           `json:"finishedAt"`
+}
+
+func (s *Server) handleScenesList(w http.ResponseWriter, r *http.Request) {
+	items, err := s.app.Scenes.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	out := make([]sceneDTO, 0, len(items))
+	for _, sc := range items {
+		out = append(out, toSceneDTO(sc))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleScenesCreate(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeSceneReq(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	sc := storage.Scene{
+		ID:        newID(),
+		Name:      req.Name,
+		StepsJSON: app.EncodeSceneSteps(req.Steps),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	setAuditResource(r, "scene", sc.ID)
+
+	if err := s.app.Scenes.Create(r.Context(), sc); err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			writeError(w, http.StatusConflict, "conflict", "scene name already in use")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, toSceneDTO(sc))
+}
+
+func (s *Server) handleScenesGet(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.app.Scenes.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "scene not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, toSceneDTO(sc))
+}
+
+// handleScenesUpdate заменяет сцену целиком (PUT); идущий запуск доигрывает старые шаги.
+func (s *Server) handleScenesUpdate(w http.ResponseWriter, r *http.Request) {
+	setAuditResource(r, "scene", r.PathValue("id"))
+	cur, err := s.app.Scenes.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "scene not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	req, ok := s.decodeSceneReq(w, r)
+	if !ok {
+		return
+	}
+
+	cur.Name = req.Name
+	cur.StepsJSON = app.EncodeSceneSteps(req.Steps)
+	cur.UpdatedAt = time.Now().UTC()
+
+	updated, err := s.app.Scenes.Update(r.Context(), cur)
+	if err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			writeError(w, http.StatusConflict, "conflict", "scene name already in use")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if !updated {
+		writeError(w, http.StatusNotFound, "not_found", "scene not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, toSceneDTO(cur))
+}
+
+func (s *Server) handleScenesDelete(w http.ResponseWriter, r *http.Request) {
+	setAuditResource(r, "scene", r.PathValue("id"))
+	if _, err := s.app.DeleteScene(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleScenesActivate запускает сцену. 202: шаги без задержки уже отправлены,
+// отложенные — scheduled; прогресс — GET .../runs/{runId}.
+func (s *Server) handleScenesActivate(w http.ResponseWriter, r *http.Request) {
+	setAuditResource(r, "scene", r.PathValue("id"))
+	st, err := s.app.ActivateScene(r.Context(), r.PathValue("id"), app.SourceAPI, s.deviceLimit())
+	if err != nil {
+		if errors.Is(err, app.ErrSceneNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", "scene not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	setAuditResource(r, "scene_run", st.ID)
+
+	writeJSON(w, http.StatusAccepted, toSceneRunDTO(st))
+}
+
+// handleSceneRunsList — последние запуски сцены, новые первыми (?limit=).
+func (s *Server) handleSceneRunsList(w http.ResponseWriter, r *http.Request) {
+	limit := sceneRunsDefaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > sceneRunsMaxLimit {
+			writeError(w, http.StatusBadRequest, "bad_request", "limit must be 1.."+strconv.Itoa(sceneRunsMaxLimit))
+			return
+		}
+	}
+
+	id := r.PathValue("id")
+	if _, err := s.app.Scenes.Get(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "scene not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	runs, err := s.app.ListSceneRuns(r.Context(), id, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	out := make([]sceneRunDTO, 0, len(runs))
+	for _, st := range runs {
+		out = append(out, toSceneRunDTO(st))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleSceneRunsGet(w http.ResponseWriter, r *http.Request) {
+	st, err := s.app.SceneRunStatus(r.Context(), r.PathValue("runId"))
+	if err != nil {
+		if errors.Is(err, app.ErrSceneRunNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", "scene run not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if st.SceneID != r.PathValue("id") {
+		writeError(w, http.StatusNotFound, "not_found", "scene run not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, toSceneRunDTO(st))
+}
+
+// decodeSceneReq разбирает и проверяет тело сцены; при ошибке уже ответил клиенту.
+func (s *Server) decodeSceneReq(w http.ResponseWriter, r *http.Request) (sceneReq, bool) {
+	var req sceneReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return sceneReq{}, false
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "name required")
+		return sceneReq{}, false
+	}
+	if err := s.app.CheckSceneSteps(r.Context(), req.Steps); err != nil {
+		if errors.Is(err, app.ErrInvalidScene) {
+			writeError(w, http.StatusUnprocessableEntity, "invalid_scene", err.Error())
+			return sceneReq{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return sceneReq{}, false
+	}
+	return req, true
+}
+
+func toSceneDTO(sc storage.Scene) sceneDTO {
+	// в БД лежит только то, что прошло CheckSceneSteps
+	steps, _ := app.ParseSceneSteps(sc)
+	return sceneDTO{
+		ID:        sc.ID,
+		Name:      sc.Name,
+		Steps:     steps,
+		CreatedAt: sc.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt: sc.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+func toSceneRunDTO(st app.SceneRunStatus) sceneRunDTO {
+	steps := make([]sceneRunStepDTO, 0, len(st.Steps))
+	for _, s := range st.Steps {
+		steps = append(steps, sceneRunStepDTO{
+			DeviceID:     s.DeviceID,
+			Action:       s.Action,
+			Delay:        s.Delay,
+			Status:       s.Status,
+			CommandID:    s.CommandID,
+			Error:        s.Error,
+			DispatchedAt: formatOptTime(s.DispatchedAt),
+		})
+	}
+
+	return sceneRunDTO{
+		ID:         st.ID,
+		SceneID:    st.SceneID,
+		Source:     st.Source,
+		Status:     st.Status,
+		Outcome:    st.Outcome,
+		Counts:     st.Counts,
+		Steps:      steps,
+		StartedAt:  st.StartedAt.UTC().Format(time.RFC3339Nano),
+		FinishedAt: formatOptTime(st.FinishedAt),
+	}
+}
@@ -0,0 +1,262 @@
+package httpapi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/schedule"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+const (
+	scheduleNextDefaultCount = 5
+	scheduleNextMaxCount     = 50
+)
+
+type scheduleReq struct {
+	Name    string `json:"name"`
+	Enabled *bool  `json:"enabled"`
+	schedule.Spec
+}
+
+type scheduleDTO struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	schedule.Spec
+	NextRunAt *string `json:"nextRunAt"`
+
+	LastRunAt      *string `json:"lastRunAt"`
+	LastStatus     string  `json:"lastStatus,omitempty"` // ok|error|missed
+	LastError      string  `json:"lastError,omitempty"`
+	LastCommandID  string  `json:"lastCommandId,omitempty"`
+	LastSceneRunID string  `json:"lastSceneRunId,omitempty"`
+
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// scheduleNextDTO — ближайшие срабатывания по часам расписания (RFC3339 со смещением).
+type scheduleNextDTO struct {
+	Timezone string   `json:"timezone"`
+	Enabled  bool     `json:"enabled"`
+	NextRuns []string `json:"nextRuns"`
+}
+
+func (s *Server) handleSchedulesList(w http.ResponseWriter, r *http.Request) {
+	items, err := s.app.Schedules.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	out := make([]scheduleDTO, 0, len(items))
+	for _, sc := range items {
+		out = append(out, s.toScheduleDTO(sc))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleSchedulesCreate(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodeScheduleReq(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	sc := storage.Schedule{
+		ID:        newID(),
+		Name:      req.Name,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	req.Spec.Encode(&sc)
+	sc.NextRunAt = s.nextRun(sc, req.Spec, now)
+	setAuditResource(r, "schedule", sc.ID)
+
+	if err := s.app.Schedules.Create(r.Context(), sc); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, s.toScheduleDTO(sc))
+}
+
+func (s *Server) handleSchedulesGet(w http.ResponseWriter, r *http.Request) {
+	sc, ok := s.loadSchedule(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.toScheduleDTO(sc))
+}
+
+// handleSchedulesUpdate заменяет расписание целиком (PUT); следующее
+// срабатывание пересчитывается от текущего момента.
+func (s *Server) handleSchedulesUpdate(w http.ResponseWriter, r *http.Request) {
+	setAuditResource(r, "schedule", r.PathValue("id"))
+	cur, ok := s.loadSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := s.decodeScheduleReq(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	cur.Name = req.Name
+	cur.Enabled = req.Enabled == nil || *req.Enabled
+	cur.UpdatedAt = now
+	req.Spec.Encode(&cur)
+	cur.NextRunAt = s.nextRun(cur, req.Spec, now)
+
+	updated, err := s.app.Schedules.Update(r.Context(), cur)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if !updated {
+		writeError(w, http.StatusNotFound, "not_found", "schedule not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.toScheduleDTO(cur))
+}
+
+func (s *Server) handleSchedulesDelete(w http.ResponseWriter, r *http.Request) {
+	setAuditResource(r, "schedule", r.PathValue("id"))
+	if _, err := s.app.Schedules.Delete(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSchedulesNext — ближайшие ?count= срабатываний от текущего момента
+// (считаются и для выключенного расписания).
+func (s *Server) handleSchedulesNext(w http.ResponseWriter, r *http.Request) {
+	count := scheduleNextDefaultCount
+	if v := r.URL.Query().Get("count"); v != "" {
+		var err error
+		if count, err = strconv.Atoi(v); err != nil || count <= 0 || count > scheduleNextMaxCount {
+			writeError(w, http.StatusBadRequest, "bad_request", "count must be 1.."+strconv.Itoa(scheduleNextMaxCount))
+			return
+		}
+	}
+
+	sc, ok := s.loadSchedule(w, r)
+	if !ok {
+		return
+	}
+	// в БД лежит только то, что прошло Validate
+	spec, _ := schedule.ParseSpec(sc)
+	loc := s.planner.Zone(spec)
+
+	out := scheduleNextDTO{Timezone: loc.String(), Enabled: sc.Enabled, NextRuns: []string{}}
+	for _, at := range s.planner.Upcoming(spec, time.Now(), count) {
+		out.NextRuns = append(out.NextRuns, at.In(loc).Format(time.RFC3339))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) loadSchedule(w http.ResponseWriter, r *http.Request) (storage.Schedule, bool) {
+	sc, err := s.app.Schedules.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "schedule not found")
+			return storage.Schedule{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return storage.Schedule{}, false
+	}
+	return sc, true
+}
+
+// decodeScheduleReq разбирает и валидирует тело расписания; при ошибке уже ответил клиенту.
+func (s *Server) decodeScheduleReq(w http.ResponseWriter, r *http.Request) (scheduleReq, bool) {
+	var req scheduleReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return scheduleReq{}, false
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "name required")
+		return scheduleReq{}, false
+	}
+	if err := s.planner.Validate(req.Spec); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_schedule", err.Error())
+		return scheduleReq{}, false
+	}
+
+	t := req.Spec.Target
+	if t.SceneID != "" {
+		if _, err := s.app.Scenes.Get(r.Context(), t.SceneID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusUnprocessableEntity, "invalid_schedule", "unknown scene: "+t.SceneID)
+				return scheduleReq{}, false
+			}
+			writeError(w, http.StatusInternalServerError, "internal", err.Error())
+			return scheduleReq{}, false
+		}
+		return req, true
+	}
+
+	if _, _, err := s.app.CheckCommand(r.Context(), t.DeviceID, t.Action, t.Params); err != nil {
+		switch {
+		case errors.Is(err, app.ErrDeviceNotFound):
+			writeError(w, http.StatusUnprocessableEntity, "invalid_schedule", "unknown device: "+t.DeviceID)
+		case errors.Is(err, app.ErrUnsupportedAction), errors.Is(err, app.ErrInvalidParams):
+			writeError(w, http.StatusUnprocessableEntity, "invalid_schedule", err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		}
+		return scheduleReq{}, false
+	}
+	return req, true
+}
+
+// nextRun — next_run_at для сохранения: nil у выключенного расписания.
+func (s *Server) nextRun(sc storage.Schedule, spec schedule.Spec, now time.Time) *time.Time {
+	if !sc.Enabled {
+		return nil
+	}
+	at, ok := s.planner.Next(spec, now)
+	if !ok {
+		return nil
+	}
+	return &at
+}
+
+func (s *Server) toScheduleDTO(sc storage.Schedule) scheduleDTO {
+	// в БД лежит только то, что прошло Validate
+	spec, _ := schedule.ParseSpec(sc)
+	spec.Timezone = s.planner.Zone(spec).String()
+
+	return scheduleDTO{
+		ID:             sc.ID,
+		Name:           sc.Name,
+		Enabled:        sc.Enabled,
+		Spec:           spec,
+		NextRunAt:      formatOptTime(sc.NextRunAt),
+		LastRunAt:      formatOptTime(sc.LastRunAt),
+		LastStatus:     sc.LastStatus,
+		LastError:      sc.LastError,
+		LastCommandID:  sc.LastCommandID,
+		LastSceneRunID: sc.LastSceneRunID,
+		CreatedAt:      sc.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt:      sc.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+func formatOptTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.UTC().Format(time.RFC3339Nano)
+	return &s
+}
@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type deviceStateDTO struct {
+	DeviceID  string          `json:"deviceId"`
+	State     json.RawMessage `json:"state"`
+	UpdatedAt string          `json:"updatedAt"`
+}
+
+func (s *Server) handleDeviceStateGet(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	// сначала проверяем устройство, чтобы отличать "нет устройства" от "нет состояния"
+	if _, err := s.app.Devices.Get(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "device not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	st, err := s.app.States.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "no_state", "device has not reported state yet")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deviceStateDTO{
+		DeviceID:  st.DeviceID,
+		State:     json.RawMessage(st.StateJSON),
+		UpdatedAt: st.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	})
+}
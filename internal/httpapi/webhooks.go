@@ -0,0 +1,340 @@
+package httpapi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+	"github.com/ArthurGuatsaev/smarthome/internal/webhook"
+)
+
+const (
+	deliveriesDefaultLimit = 50
+	deliveriesMaxLimit     = 500
+)
+
+type createWebhookReq struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"eventTypes"` // пусто — все события
+	Secret     string   `json:"secret"`     // пусто — сгенерировать
+	Enabled    *bool    `json:"enabled"`
+}
+
+// updateWebhookReq — PATCH: меняются только переданные поля; secret — ротация.
+type updateWebhookReq struct {
+	URL        *string   `json:"url"`
+	EventTypes *[]string `json:"eventTypes"`
+	Secret     *string   `json:"secret"`
+	Enabled    *bool     `json:"enabled"`
+}
+
+type webhookDTO struct {
+	ID         string   `json:"id"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+	Secret     string   `json:"secret,omitempty"` // только в ответе на создание и смену секрета
+	Enabled    bool     `json:"enabled"`
+	CreatedAt  string   `json:"createdAt"`
+	UpdatedAt  string   `json:"updatedAt"`
+}
+
+type deliveryDTO struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhookId"`
+	EventType      string          `json:"eventType"`
+	Status         string          `json:"status"` // pending|delivered|dead
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *string         `json:"nextAttemptAt"`
+	LastStatusCode int             `json:"lastStatusCode,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      string          `json:"createdAt"`
+	UpdatedAt      string          `json:"updatedAt"`
+}
+
+func (s *Server) handleWebhooksList(w http.ResponseWriter, r *http.Request) {
+	items, err := s.app.Webhooks.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	out := make([]webhookDTO, 0, len(items))
+	for _, wh := range items {
+		out = append(out, toWebhookDTO(wh))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleWebhooksCreate(w http.ResponseWriter, r *http.Request) {
+	var req createWebhookReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return
+	}
+	if req.Secret == "" {
+		req.Secret = webhook.NewSecret()
+	}
+	if err := validateWebhook(req.URL, req.EventTypes, req.Secret); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_webhook", err.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	wh := storage.Webhook{
+		ID:             newID(),
+		URL:            req.URL,
+		EventTypesJSON: encodeEventTypes(req.EventTypes),
+		Secret:         req.Secret,
+		Enabled:        req.Enabled == nil || *req.Enabled,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	setAuditResource(r, "webhook", wh.ID)
+
+	if err := s.app.Webhooks.Create(r.Context(), wh); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	dto := toWebhookDTO(wh)
+	dto.Secret = wh.Secret
+	writeJSON(w, http.StatusCreated, dto)
+}
+
+func (s *Server) handleWebhooksGet(w http.ResponseWriter, r *http.Request) {
+	wh, ok := s.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, toWebhookDTO(wh))
+}
+
+func (s *Server) handleWebhooksUpdate(w http.ResponseWriter, r *http.Request) {
+	setAuditResource(r, "webhook", r.PathValue("id"))
+
+	var req updateWebhookReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json")
+		return
+	}
+
+	wh, ok := s.loadWebhook(w, r)
+	if !ok {
+		return
+	}
+	if req.URL != nil {
+		wh.URL = *req.URL
+	}
+	var types []string
+	_ = json.Unmarshal([]byte(wh.EventTypesJSON), &types)
+	if req.EventTypes != nil {
+		types = *req.EventTypes
+	}
+	if req.Secret != nil {
+		wh.Secret = *req.Secret
+		if wh.Secret == "" {
+			wh.Secret = webhook.NewSecret()
+		}
+	}
+	if req.Enabled != nil {
+		wh.Enabled = *req.Enabled
+	}
+	if err := validateWebhook(wh.URL, types, wh.Secret); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_webhook", err.Error())
+		return
+	}
+	wh.EventTypesJSON = encodeEventTypes(types)
+	wh.UpdatedAt = time.Now().UTC()
+
+	updated, err := s.app.Webhooks.Update(r.Context(), wh)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if !updated {
+		writeError(w, http.StatusNotFound, "not_found", "webhook not found")
+		return
+	}
+	dto := toWebhookDTO(wh)
+	if req.Secret != nil {
+		dto.Secret = wh.Secret
+	}
+	writeJSON(w, http.StatusOK, dto)
+}
+
+func (s *Server) handleWebhooksDelete(w http.ResponseWriter, r *http.Request) {
+	setAuditResource(r, "webhook", r.PathValue("id"))
+	if _, err := s.app.Webhooks.Delete(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleWebhookDeliveries — журнал доставок подписки, новые первыми.
+// Фильтр ?status=pending,delivered,dead; страница ?limit=&cursor=,
+// курсор следующей — в заголовке X-Next-Cursor.
+func (s *Server) handleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	q, ok := decodeDeliveryQuery(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := s.loadWebhook(w, r); !ok {
+		return
+	}
+	q.WebhookID = r.PathValue("id")
+	s.writeDeliveries(w, r, q)
+}
+
+// handleWebhookDeadLetters — dead-доставки всех подписок (попытки исчерпаны).
+func (s *Server) handleWebhookDeadLetters(w http.ResponseWriter, r *http.Request) {
+	q, ok := decodeDeliveryQuery(w, r)
+	if !ok {
+		return
+	}
+	q.Statuses = []string{storage.DeliveryDead}
+	s.writeDeliveries(w, r, q)
+}
+
+// handleWebhookDeliveryRetry возвращает dead-доставку в очередь со сброшенным счётчиком попыток.
+func (s *Server) handleWebhookDeliveryRetry(w http.ResponseWriter, r *http.Request) {
+	setAuditResource(r, "webhook_delivery", r.PathValue("deliveryId"))
+
+	dl, err := s.app.Deliveries.Get(r.Context(), r.PathValue("deliveryId"))
+	if err != nil || dl.WebhookID != r.PathValue("id") {
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "delivery not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	requeued, err := s.app.Deliveries.Requeue(r.Context(), dl.ID, time.Now().UTC())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if !requeued {
+		writeError(w, http.StatusConflict, "conflict", "only dead deliveries can be retried")
+		return
+	}
+
+	if dl, err = s.app.Deliveries.Get(r.Context(), dl.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, toDeliveryDTO(dl))
+}
+
+func (s *Server) loadWebhook(w http.ResponseWriter, r *http.Request) (storage.Webhook, bool) {
+	wh, err := s.app.Webhooks.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "webhook not found")
+			return storage.Webhook{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return storage.Webhook{}, false
+	}
+	return wh, true
+}
+
+// decodeDeliveryQuery разбирает ?status=&limit=&cursor=; при ошибке уже ответил клиенту.
+func decodeDeliveryQuery(w http.ResponseWriter, r *http.Request) (storage.DeliveryListQuery, bool) {
+	q := r.URL.Query()
+	lq := storage.DeliveryListQuery{Cursor: q.Get("cursor"), Limit: deliveriesDefaultLimit}
+
+	if v := q.Get("status"); v != "" {
+		for _, st := range strings.Split(v, ",") {
+			switch st {
+			case storage.DeliveryPending, storage.DeliveryDelivered, storage.DeliveryDead:
+				lq.Statuses = append(lq.Statuses, st)
+			default:
+				writeError(w, http.StatusBadRequest, "bad_request", "status must be pending, delivered or dead")
+				return storage.DeliveryListQuery{}, false
+			}
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if lq.Limit, err = strconv.Atoi(v); err != nil || lq.Limit <= 0 || lq.Limit > deliveriesMaxLimit {
+			writeError(w, http.StatusBadRequest, "bad_request", "limit must be 1.."+strconv.Itoa(deliveriesMaxLimit))
+			return storage.DeliveryListQuery{}, false
+		}
+	}
+	return lq, true
+}
+
+func (s *Server) writeDeliveries(w http.ResponseWriter, r *http.Request, q storage.DeliveryListQuery) {
+	page, err := s.app.Deliveries.List(r.Context(), q)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid cursor")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	out := make([]deliveryDTO, 0, len(page.Items))
+	for _, dl := range page.Items {
+		out = append(out, toDeliveryDTO(dl))
+	}
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func validateWebhook(url string, types []string, secret string) error {
+	if err := webhook.ValidateURL(url); err != nil {
+		return err
+	}
+	if err := webhook.ValidateFilter(types); err != nil {
+		return err
+	}
+	return webhook.ValidateSecret(secret)
+}
+
+func encodeEventTypes(types []string) string {
+	if types == nil {
+		types = []string{}
+	}
+	b, _ := json.Marshal(types)
+	return string(b)
+}
+
+func toWebhookDTO(wh storage.Webhook) webhookDTO {
+	types := []string{}
+	_ = json.Unmarshal([]byte(wh.EventTypesJSON), &types)
+	return webhookDTO{
+		ID:         wh.ID,
+		URL:        wh.URL,
+		EventTypes: types,
+		Enabled:    wh.Enabled,
+		CreatedAt:  wh.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt:  wh.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+func toDeliveryDTO(dl storage.WebhookDelivery) deliveryDTO {
+	return deliveryDTO{
+		ID:             dl.ID,
+		WebhookID:      dl.WebhookID,
+		EventType:      dl.EventType,
+		Status:         dl.Status,
+		Attempts:       dl.Attempts,
+		NextAttemptAt:  formatOptTime(dl.NextAttemptAt),
+		LastStatusCode: dl.LastStatusCode,
+		LastError:      dl.LastError,
+		Payload:        json.RawMessage(dl.PayloadJSON),
+		CreatedAt:      dl.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt:      dl.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
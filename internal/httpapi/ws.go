@@ -0,0 +1,236 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+	wsMaxMessage = 64 << 10
+	wsAllDevices = "*"
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	// ключ проверяет RequireAPIKey, а браузерные клиенты (планшеты) ходят с других origin
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsIn — сообщение от клиента.
+//
+//	{"type":"subscribe","deviceIds":["id1","id2"]}   ("*" — все устройства)
+//	{"type":"unsubscribe","deviceIds":["id1"]}
+//	{"type":"command","ref":"c1","deviceId":"id1","action":"turn_on","params":{}}
+type wsIn struct {
+	Type      string          `json:"type"`
+	Ref       string          `json:"ref,omitempty"`
+	DeviceIDs []string        `json:"deviceIds,omitempty"`
+	DeviceID  string          `json:"deviceId,omitempty"`
+	Action    string          `json:"action,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+}
+
+// wsOut — сообщение клиенту: event, subscribed, command_result или error.
+type wsOut struct {
+	Type      string      `json:"type"`
+	Ref       string      `json:"ref,omitempty"`
+	DeviceIDs []string    `json:"deviceIds,omitempty"`
+	Event     *app.Event  `json:"event,omitempty"`
+	Command   *commandDTO `json:"command,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Details   string      `json:"details,omitempty"`
+}
+
+// wsConn — состояние одного соединения: подписки на устройства и команды,
+// отправленные через это соединение (их ack/timeout приходят всегда).
+type wsConn struct {
+	mu       sync.Mutex
+	devices  map[string]bool
+	commands map[string]bool
+
+	out chan wsOut
+}
+
+func (c *wsConn) wants(e app.Event) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e.CommandID != "" && c.commands[e.CommandID] {
+		return true
+	}
+	return c.devices[wsAllDevices] || (e.DeviceID != "" && c.devices[e.DeviceID])
+}
+
+func (c *wsConn) setDevices(ids []string, on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range ids {
+		if on {
+			c.devices[id] = true
+		} else {
+			delete(c.devices, id)
+		}
+	}
+}
+
+func (c *wsConn) subscribed() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]string, 0, len(c.devices))
+	for id := range c.devices {
+		out = append(out, id)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func (c *wsConn) trackCommand(id string, on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if on {
+		c.commands[id] = true
+	} else {
+		delete(c.commands, id)
+	}
+}
+
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	ws, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade уже ответил клиенту
+		return
+	}
+	defer ws.Close()
+
+	conn := &wsConn{
+		devices:  map[string]bool{},
+		commands: map[string]bool{},
+		out:      make(chan wsOut, 16),
+	}
+	sub := s.app.Events.Subscribe(0, conn.wants)
+	defer sub.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.wsWriter(ws, sub, conn.out, done)
+
+	ws.SetReadLimit(wsMaxMessage)
+	_ = ws.SetReadDeadline(time.Now().Add(wsPongWait))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var msg wsIn
+		if err := ws.ReadJSON(&msg); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				slog.Debug("ws_read_error", "err", err)
+			}
+			return
+		}
+
+		reply := s.wsHandleMessage(r, conn, msg)
+		select {
+		case conn.out <- reply:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) wsHandleMessage(r *http.Request, conn *wsConn, msg wsIn) wsOut {
+	switch msg.Type {
+	case "subscribe", "unsubscribe":
+		if len(msg.DeviceIDs) == 0 {
+			return wsOut{Type: "error", Ref: msg.Ref, Error: "bad_request", Details: "deviceIds required"}
+		}
+		conn.setDevices(msg.DeviceIDs, msg.Type == "subscribe")
+		return wsOut{Type: "subscribed", Ref: msg.Ref, DeviceIDs: conn.subscribed()}
+
+	case "command":
+		p, _ := PrincipalFrom(r.Context())
+		if !p.Has(app.ScopeCommandsSend) {
+			return wsOut{Type: "error", Ref: msg.Ref, Error: "forbidden", Details: "api key lacks scope " + app.ScopeCommandsSend}
+		}
+		if msg.DeviceID == "" || msg.Action == "" {
+			return wsOut{Type: "error", Ref: msg.Ref, Error: "bad_request", Details: "deviceId and action required"}
+		}
+		if ok, retry := s.allowCommand(nil, p.Name, msg.DeviceID); !ok {
+			return wsOut{Type: "error", Ref: msg.Ref, Error: "rate_limited", Details: "retry after " + retry.Round(time.Millisecond).String()}
+		}
+		// command.created, ack и timeout по этой команде придут в это соединение,
+		// даже если устройство ответит раньше, чем CreateCommand вернётся
+		id := app.NewCommandID()
+		conn.trackCommand(id, true)
+		c, err := s.app.CreateCommand(r.Context(), app.CommandRequest{
+			ID:       id,
+			DeviceID: msg.DeviceID,
+			Action:   msg.Action,
+			Params:   msg.Params,
+			Source:   app.SourceWS,
+		})
+		s.auditWS(r, msg.DeviceID, c, err)
+		if c.ID == "" {
+			// команда не создана: событий по ней не будет
+			conn.trackCommand(id, false)
+		}
+		if err != nil {
+			_, code := commandErrorCode(err)
+			return wsOut{Type: "error", Ref: msg.Ref, Error: code, Details: err.Error()}
+		}
+		dto := toCommandDTO(c)
+		return wsOut{Type: "command_result", Ref: msg.Ref, Command: &dto}
+
+	default:
+		return wsOut{Type: "error", Ref: msg.Ref, Error: "bad_request", Details: "unknown message type"}
+	}
+}
+
+// wsWriter — единственный писатель в соединение (gorilla не допускает конкурентной записи).
+func (s *Server) wsWriter(ws *websocket.Conn, sub *app.Subscription, out <-chan wsOut, done <-chan struct{}) {
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	write := func(v wsOut) bool {
+		_ = ws.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		return ws.WriteJSON(v) == nil
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case m := <-out:
+			if !write(m) {
+				ws.Close()
+				return
+			}
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if !write(wsOut{Type: "event", Event: &e}) {
+				ws.Close()
+				return
+			}
+		case <-ping.C:
+			_ = ws.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				ws.Close()
+				return
+			}
+		}
+	}
+}
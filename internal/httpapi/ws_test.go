@@ -0,0 +1,76 @@
+package httpapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/httpapi"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+	"github.com/ArthurGuatsaev/smarthome/internal/testutil"
+)
+
+type wsMessage struct {
+	Type    string     `json:"type"`
+	Ref     string     `json:"ref"`
+	Event   *app.Event `json:"event"`
+	Command *struct {
+		ID string `json:"id"`
+	} `json:"command"`
+	Error string `json:"error"`
+}
+
+// Соединение получает события своей команды без подписки на устройство,
+// включая command.created, опубликованный до ответа command_result.
+func TestWSCommandEventsWithoutSubscription(t *testing.T) {
+	a := testutil.NewApp(t, nil)
+	d := storage.Device{ID: "lamp", Name: "lamp", Type: "light", MQTTDeviceID: "lamp", Capabilities: `["on_off"]`, CreatedAt: time.Now().UTC()}
+	if _, err := a.CreateDevice(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(httpapi.NewServer(a, httpapi.Config{}).Handler())
+	defer srv.Close()
+
+	header := http.Header{"X-API-Key": {testutil.AdminKey}}
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/v1/ws", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	_ = ws.SetReadDeadline(time.Now().Add(testutil.WaitTimeout))
+
+	cmd := map[string]any{"type": "command", "ref": "c1", "deviceId": "lamp", "action": "turn_on"}
+	if err := ws.WriteJSON(cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	var created *app.Event
+	var commandID string
+	for created == nil || commandID == "" {
+		var m wsMessage
+		if err := ws.ReadJSON(&m); err != nil {
+			t.Fatalf("read (created=%v, commandId=%q): %v", created != nil, commandID, err)
+		}
+		switch m.Type {
+		case "event":
+			if m.Event.Type == app.EventCommandCreated {
+				created = m.Event
+			}
+		case "command_result":
+			commandID = m.Command.ID
+		default:
+			raw, _ := json.Marshal(m)
+			t.Fatalf("unexpected message %s", raw)
+		}
+	}
+	if created.CommandID != commandID {
+		t.Fatalf("command.created for %q, want %q", created.CommandID, commandID)
+	}
+}
@@ -0,0 +1,122 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/metrics"
+	"github.com/ArthurGuatsaev/smarthome/internal/mqtt"
+)
+
+// Таймаут на обработку одного сообщения (запись в БД)
+const handleTimeout = 5 * time.Second
+
+// Метка result в smarthome_mqtt_messages_total
+const (
+	resultOK       = "ok"
+	resultRejected = "rejected"
+	resultError    = "error"
+)
+
+func countMessage(kind, result string) {
+	metrics.MQTTMessages.WithLabelValues(kind, result).Inc()
+}
+
+type Ingester struct {
+	app *app.App
+}
+
+func New(a *app.App) *Ingester {
+	return &Ingester{app: a}
+}
+
+// Subscriber — источник сообщений устройств; в рабочем режиме это *mqtt.Client.
+type Subscriber interface {
+	Subscribe(filter string, qos byte, h mqtt.Handler)
+}
+
+// Register подписывает обработчики на топики устройств.
+func (in *Ingester) Register(s Subscriber) {
+	s.Subscribe(mqtt.TelemetryFilter, 1, in.HandleTelemetry)
+	s.Subscribe(mqtt.AckFilter, 1, in.HandleAck)
+	s.Subscribe(mqtt.StatusFilter, 1, in.HandleStatus)
+}
+
+func (in *Ingester) HandleTelemetry(topic string, payload []byte) {
+	homeID, mqttDeviceID, kind, ok := mqtt.ParseDeviceTopic(topic)
+	if !ok || kind != mqtt.KindTelemetry {
+		slog.Warn("telemetry_bad_topic", "topic", topic)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), handleTimeout)
+	defer cancel()
+
+	err := in.app.HandleTelemetry(ctx, homeID, mqttDeviceID, payload)
+	switch {
+	case err == nil:
+		countMessage(mqtt.KindTelemetry, resultOK)
+		slog.Debug("telemetry_ok", "home_id", homeID, "mqtt_device_id", mqttDeviceID)
+	case errors.Is(err, app.ErrDeviceNotFound), errors.Is(err, app.ErrInvalidTelemetry):
+		countMessage(mqtt.KindTelemetry, resultRejected)
+		slog.Warn("telemetry_rejected", "home_id", homeID, "mqtt_device_id", mqttDeviceID, "err", err)
+	default:
+		countMessage(mqtt.KindTelemetry, resultError)
+		slog.Error("telemetry_error", "home_id", homeID, "mqtt_device_id", mqttDeviceID, "err", err)
+	}
+}
+
+func (in *Ingester) HandleAck(topic string, payload []byte) {
+	homeID, mqttDeviceID, kind, ok := mqtt.ParseDeviceTopic(topic)
+	if !ok || kind != mqtt.KindAck {
+		slog.Warn("ack_bad_topic", "topic", topic)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), handleTimeout)
+	defer cancel()
+
+	err := in.app.HandleAck(ctx, homeID, mqttDeviceID, payload)
+	switch {
+	case err == nil:
+		countMessage(mqtt.KindAck, resultOK)
+		slog.Debug("ack_ok", "home_id", homeID, "mqtt_device_id", mqttDeviceID)
+	case errors.Is(err, app.ErrCommandNotPending):
+		countMessage(mqtt.KindAck, resultRejected)
+		slog.Info("ack_late", "home_id", homeID, "mqtt_device_id", mqttDeviceID)
+	case errors.Is(err, app.ErrDeviceNotFound), errors.Is(err, app.ErrInvalidAck),
+		errors.Is(err, app.ErrCommandNotFound), errors.Is(err, app.ErrAckDeviceMismatch):
+		countMessage(mqtt.KindAck, resultRejected)
+		slog.Warn("ack_rejected", "home_id", homeID, "mqtt_device_id", mqttDeviceID, "err", err)
+	default:
+		countMessage(mqtt.KindAck, resultError)
+		slog.Error("ack_error", "home_id", homeID, "mqtt_device_id", mqttDeviceID, "err", err)
+	}
+}
+
+func (in *Ingester) HandleStatus(topic string, payload []byte) {
+	homeID, mqttDeviceID, kind, ok := mqtt.ParseDeviceTopic(topic)
+	if !ok || kind != mqtt.KindStatus {
+		slog.Warn("status_bad_topic", "topic", topic)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), handleTimeout)
+	defer cancel()
+
+	err := in.app.HandleStatus(ctx, homeID, mqttDeviceID, payload)
+	switch {
+	case err == nil:
+		countMessage(mqtt.KindStatus, resultOK)
+		slog.Debug("status_ok", "home_id", homeID, "mqtt_device_id", mqttDeviceID)
+	case errors.Is(err, app.ErrDeviceNotFound), errors.Is(err, app.ErrInvalidStatus):
+		countMessage(mqtt.KindStatus, resultRejected)
+		slog.Warn("status_rejected", "home_id", homeID, "mqtt_device_id", mqttDeviceID, "err", err)
+	default:
+		countMessage(mqtt.KindStatus, resultError)
+		slog.Error("status_error", "home_id", homeID, "mqtt_device_id", mqttDeviceID, "err", err)
+	}
+}
@@ -0,0 +1,82 @@
+package ingest_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/ingest"
+	"github.com/ArthurGuatsaev/smarthome/internal/mqtt"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+	"github.com/ArthurGuatsaev/smarthome/internal/testutil"
+)
+
+func newTestApp(t *testing.T) *app.App {
+	t.Helper()
+	a := testutil.NewApp(t, nil)
+	d := storage.Device{ID: "lamp", Name: "lamp", Type: "light", MQTTDeviceID: "lamp-01", Capabilities: `["on_off"]`, HomeID: storage.DefaultHomeID, CreatedAt: time.Now().UTC()}
+	if err := a.Devices.Create(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestTelemetryThroughBroker(t *testing.T) {
+	a := newTestApp(t)
+	b := testutil.NewBroker(t)
+
+	ingest.New(a).Register(b.Connect(t, "server"))
+	b.WaitSubscribed(t, mqtt.TelemetryFilter)
+
+	device := b.Connect(t, "lamp-01")
+	ctx := context.Background()
+	topic := mqtt.DeviceTopic(testutil.HomeID, "lamp-01", mqtt.KindTelemetry)
+	if err := device.Publish(ctx, topic, 1, []byte(`{ "on": true, "brightness": 40 }`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var st storage.DeviceState
+	testutil.Eventually(t, "device_state row", func() bool {
+		var err error
+		st, err = a.States.Get(ctx, "lamp")
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			t.Fatal(err)
+		}
+		return err == nil
+	})
+	if want := `{"on":true,"brightness":40}`; st.StateJSON != want {
+		t.Fatalf("state = %s, want %s", st.StateJSON, want)
+	}
+}
+
+func TestHandleTelemetryRejects(t *testing.T) {
+	telemetry := mqtt.DeviceTopic(testutil.HomeID, "lamp-01", mqtt.KindTelemetry)
+	tests := []struct {
+		name    string
+		topic   string
+		payload string
+	}{
+		{name: "ack topic", topic: mqtt.DeviceTopic(testutil.HomeID, "lamp-01", mqtt.KindAck), payload: `{"on":true}`},
+		{name: "short topic", topic: "home/1/lamp-01/telemetry", payload: `{"on":true}`},
+		{name: "extra segment", topic: "home/1/device/lamp-01/telemetry/x", payload: `{"on":true}`},
+		{name: "not json", topic: telemetry, payload: `on`},
+		{name: "array", topic: telemetry, payload: `[true]`},
+		{name: "empty object", topic: telemetry, payload: `{}`},
+		{name: "unknown device", topic: mqtt.DeviceTopic(testutil.HomeID, "ghost", mqtt.KindTelemetry), payload: `{"on":true}`},
+		{name: "other home", topic: mqtt.DeviceTopic("2", "lamp-01", mqtt.KindTelemetry), payload: `{"on":true}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(t)
+			ingest.New(a).HandleTelemetry(tt.topic, []byte(tt.payload))
+
+			if _, err := a.States.Get(context.Background(), "lamp"); !errors.Is(err, sql.ErrNoRows) {
+				t.Fatalf("state must stay empty, got err %v", err)
+			}
+		})
+	}
+}
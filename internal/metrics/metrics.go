@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "smarthome"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route pattern and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "SQLite query latency by operation (verb_table).",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"op", "result"})
+
+	MQTTMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mqtt_messages_total",
+		Help:      "Incoming MQTT messages by kind (telemetry, ack) and result (ok, rejected, error).",
+	}, []string{"kind", "result"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		DBQueryDuration,
+		MQTTMessages,
+	)
+}
+
+// RegisterDB экспортирует sql.DBStats пула соединений.
+func RegisterDB(db *sql.DB) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, "sqlite"))
+}
+
+// RegisterMQTT экспортирует состояние подключения к брокеру (1 — подключены).
+func RegisterMQTT(connected func() bool) {
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "mqtt_connected",
+		Help:      "Whether the MQTT client is connected to the broker.",
+	}, func() float64 {
+		if connected() {
+			return 1
+		}
+		return 0
+	}))
+}
+
+// ObserveDBQuery — наблюдатель для storage.SetQueryObserver.
+func ObserveDBQuery(op string, d time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	DBQueryDuration.WithLabelValues(op, result).Observe(d.Seconds())
+}
+
+// Handler отдаёт метрики в текстовом формате Prometheus.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
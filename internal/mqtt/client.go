@@ -0,0 +1,127 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+type Config struct {
+	BrokerURL string
+	ClientID  string
+	Username  string
+	Password  string
+}
+
+// Handler получает сообщение из подписки.
+type Handler func(topic string, payload []byte)
+
+type Client struct {
+	c paho.Client
+
+	mu   sync.Mutex
+	subs map[string]subscription // filter -> подписка, для переподписки после reconnect
+}
+
+type subscription struct {
+	qos byte
+	h   Handler
+}
+
+// New создаёт клиента и запускает подключение в фоне:
+// сервер должен стартовать, даже если брокер пока недоступен.
+func New(cfg Config) *Client {
+	cl := &Client{subs: map[string]subscription{}}
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.BrokerURL).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(2 * time.Second).
+		SetOrderMatters(false).
+		SetOnConnectHandler(cl.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			slog.Warn("mqtt_connection_lost", "err", err)
+		})
+
+	cl.c = paho.NewClient(opts)
+	cl.c.Connect()
+	return cl
+}
+
+func (cl *Client) onConnect(c paho.Client) {
+	slog.Info("mqtt_connected")
+
+	// clean session: подписки на брокере теряются, восстанавливаем их
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	for filter, s := range cl.subs {
+		cl.subscribe(filter, s)
+	}
+}
+
+// Subscribe регистрирует обработчик; подписка переживает переподключения.
+func (cl *Client) Subscribe(filter string, qos byte, h Handler) {
+	s := subscription{qos: qos, h: h}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.subs[filter] = s
+
+	if cl.c.IsConnectionOpen() {
+		cl.subscribe(filter, s)
+	}
+}
+
+func (cl *Client) subscribe(filter string, s subscription) {
+	tok := cl.c.Subscribe(filter, s.qos, func(_ paho.Client, m paho.Message) {
+		s.h(m.Topic(), m.Payload())
+	})
+	go func() {
+		tok.Wait()
+		if err := tok.Error(); err != nil {
+			slog.Error("mqtt_subscribe_error", "filter", filter, "err", err)
+			return
+		}
+		slog.Info("mqtt_subscribed", "filter", filter)
+	}()
+}
+
+var ErrNotConnected = errors.New("mqtt not connected")
+
+func (cl *Client) Publish(ctx context.Context, topic string, qos byte, payload []byte) error {
+	if !cl.c.IsConnectionOpen() {
+		return ErrNotConnected
+	}
+	tok := cl.c.Publish(topic, qos, false, payload)
+	select {
+	case <-tok.Done():
+		return tok.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (cl *Client) IsConnected() bool {
+	return cl.c.IsConnectionOpen()
+}
+
+// Check — проверка готовности: ошибка, если соединения с брокером нет.
+func (cl *Client) Check(ctx context.Context) error {
+	if !cl.c.IsConnectionOpen() {
+		return ErrNotConnected
+	}
+	return nil
+}
+
+func (cl *Client) Close() {
+	cl.c.Disconnect(250)
+}
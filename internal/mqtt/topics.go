@@ -0,0 +1,40 @@
+package mqtt
+
+import "strings"
+
+// Топики устройств: home/{homeId}/device/{mqttDeviceId}/{kind}
+const (
+	KindTelemetry = "telemetry"
+	KindCommand   = "command"
+	KindAck       = "ack"
+	KindStatus    = "status" // "online"/"offline", "offline" — LWT устройства
+)
+
+// Фильтры подписки на все дома и устройства
+const (
+	TelemetryFilter = "home/+/device/+/" + KindTelemetry
+	AckFilter       = "home/+/device/+/" + KindAck
+	StatusFilter    = "home/+/device/+/" + KindStatus
+)
+
+func DeviceTopic(homeID, mqttDeviceID, kind string) string {
+	return "home/" + homeID + "/device/" + mqttDeviceID + "/" + kind
+}
+
+// ParseDeviceTopic разбирает топик вида home/{homeId}/device/{mqttDeviceId}/{kind}.
+func ParseDeviceTopic(topic string) (homeID, mqttDeviceID, kind string, ok bool) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 5 || parts[0] != "home" || parts[2] != "device" {
+		return "", "", "", false
+	}
+	if parts[1] == "" || parts[3] == "" || parts[4] == "" {
+		return "", "", "", false
+	}
+	return parts[1], parts[3], parts[4], true
+}
+
+// ValidSegment: s можно подставить в топик как один уровень
+// (непустой, без разделителя и wildcard-символов).
+func ValidSegment(s string) bool {
+	return s != "" && !strings.ContainsAny(s, "/+#")
+}
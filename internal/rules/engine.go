@@ -0,0 +1,299 @@
+package rules
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+const (
+	// Как часто проверяются timer-правила
+	timerTick = time.Second
+	// Сколько помнить, что правило уже сработало в цепочке (correlationId)
+	firedTTL = 10 * time.Minute
+	// Таймаут на выполнение одного действия
+	actionTimeout = 5 * time.Second
+)
+
+// Source — значение commands.source для команд правила.
+func Source(ruleID string) string { return "rule:" + ruleID }
+
+// Engine исполняет правила: слушает device.state_changed на шине и тикает таймеры.
+//
+// Защита от циклов: команды правила несут source "rule:<id>" и correlationId
+// цепочки. Если состояние изменилось по команде (commandId в телеметрии),
+// правило не срабатывает на команду, которую породило само, и не срабатывает
+// повторно в той же цепочке (A -> B -> A).
+type Engine struct {
+	app *app.App
+
+	mu        sync.Mutex
+	stopped   bool
+	delayed   map[string][]*time.Timer // ruleID -> отложенные действия
+	lastTimer map[string]time.Time     // ruleID -> последний запуск timer-правила
+	fired     map[string]time.Time     // correlationID/ruleID -> когда сработало
+}
+
+func New(a *app.App) *Engine {
+	return &Engine{
+		app:       a,
+		delayed:   map[string][]*time.Timer{},
+		lastTimer: map[string]time.Time{},
+		fired:     map[string]time.Time{},
+	}
+}
+
+// Run блокируется до отмены ctx; отложенные действия при остановке отменяются.
+func (e *Engine) Run(ctx context.Context) {
+	sub := e.app.Events.Subscribe(256, func(ev app.Event) bool {
+		return ev.Type == app.EventDeviceStateChanged
+	})
+	defer sub.Close()
+	defer e.stop()
+
+	t := time.NewTicker(timerTick)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-sub.Events():
+			e.onStateChanged(ctx, ev)
+		case now := <-t.C:
+			e.onTick(ctx, now)
+		}
+	}
+}
+
+func (e *Engine) stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stopped = true
+	for _, timers := range e.delayed {
+		for _, t := range timers {
+			t.Stop()
+		}
+	}
+	e.delayed = map[string][]*time.Timer{}
+}
+
+func (e *Engine) onStateChanged(ctx context.Context, ev app.Event) {
+	rules, err := e.enabled(ctx)
+	if err != nil {
+		slog.Error("rules_load_error", "err", err)
+		return
+	}
+
+	var state map[string]any
+	if data, ok := ev.Data.(app.StateChangedData); ok {
+		_ = json.Unmarshal(data.State, &state)
+	}
+
+	// команда, которая привела к изменению состояния (если устройство её указало)
+	var cause *storage.Command
+	if ev.CommandID != "" {
+		if c, err := e.app.Commands.Get(ctx, ev.CommandID); err == nil {
+			cause = &c
+		}
+	}
+
+	for _, r := range rules {
+		if r.spec.Trigger.Type != TriggerStateChanged || r.spec.Trigger.DeviceID != ev.DeviceID {
+			continue
+		}
+
+		corr := app.NewCorrelationID()
+		if cause != nil {
+			if cause.Source == Source(r.ID) {
+				slog.Debug("rule_skip_own_command", "rule_id", r.ID, "command_id", cause.ID)
+				continue
+			}
+			corr = cause.CorrelationID
+		}
+		if e.firedIn(corr, r.ID) {
+			slog.Warn("rule_loop_prevented", "rule_id", r.ID, "correlation_id", corr)
+			continue
+		}
+
+		if !e.conditionsMatch(ctx, r.spec, ev.DeviceID, state) {
+			continue
+		}
+		e.fire(r, corr)
+	}
+}
+
+func (e *Engine) onTick(ctx context.Context, now time.Time) {
+	rules, err := e.enabled(ctx)
+	if err != nil {
+		slog.Error("rules_load_error", "err", err)
+		return
+	}
+
+	for _, r := range rules {
+		if r.spec.Trigger.Type != TriggerTimer {
+			continue
+		}
+		every, _ := time.ParseDuration(r.spec.Trigger.Every)
+
+		e.mu.Lock()
+		last, seen := e.lastTimer[r.ID]
+		due := seen && now.Sub(last) >= every
+		if !seen || due {
+			// первый тик только запоминает время: правило не стреляет сразу при старте
+			e.lastTimer[r.ID] = now
+		}
+		e.mu.Unlock()
+
+		if due && e.conditionsMatch(ctx, r.spec, "", nil) {
+			e.fire(r, app.NewCorrelationID())
+		}
+	}
+}
+
+type loadedRule struct {
+	ID        string
+	UpdatedAt time.Time
+	spec      Spec
+}
+
+func (e *Engine) enabled(ctx context.Context) ([]loadedRule, error) {
+	rows, err := e.app.Rules.ListEnabled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]loadedRule, 0, len(rows))
+	for _, r := range rows {
+		spec, err := ParseSpec(r)
+		if err != nil {
+			slog.Error("rule_parse_error", "rule_id", r.ID, "err", err)
+			continue
+		}
+		out = append(out, loadedRule{ID: r.ID, UpdatedAt: r.UpdatedAt, spec: spec})
+	}
+	return out, nil
+}
+
+// conditionsMatch: условия без deviceId проверяются по состоянию триггера,
+// остальные — по последнему сохранённому состоянию устройства.
+func (e *Engine) conditionsMatch(ctx context.Context, s Spec, triggerDevice string, triggerState map[string]any) bool {
+	for _, c := range s.Conditions {
+		devID := c.DeviceID
+		if devID == "" {
+			devID = triggerDevice
+		}
+
+		state := triggerState
+		if devID != triggerDevice {
+			st, err := e.app.States.Get(ctx, devID)
+			if err != nil {
+				if !errors.Is(err, sql.ErrNoRows) {
+					slog.Error("rule_state_error", "device_id", devID, "err", err)
+				}
+				return false
+			}
+			state = nil
+			_ = json.Unmarshal([]byte(st.StateJSON), &state)
+		}
+
+		if !c.Match(state) {
+			return false
+		}
+	}
+	return true
+}
+
+// fire выполняет действия правила. Повторное срабатывание отменяет ещё не
+// выполненные отложенные действия: "свет на 2 минуты" отсчитывается заново.
+// Отложенное действие пропускается, если правило к этому времени удалили,
+// выключили или изменили.
+func (e *Engine) fire(r loadedRule, corr string) {
+	slog.Info("rule_fired", "rule_id", r.ID, "correlation_id", corr)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return
+	}
+
+	e.fired[corr+"/"+r.ID] = time.Now()
+	for _, t := range e.delayed[r.ID] {
+		t.Stop()
+	}
+	delete(e.delayed, r.ID)
+
+	for _, a := range r.spec.Actions {
+		delay, _ := time.ParseDuration(a.Delay)
+		if delay <= 0 {
+			go e.dispatch(r.ID, a, corr)
+			continue
+		}
+		t := time.AfterFunc(delay, func() {
+			if e.unchanged(r) {
+				e.dispatch(r.ID, a, corr)
+			}
+		})
+		e.delayed[r.ID] = append(e.delayed[r.ID], t)
+	}
+}
+
+// unchanged сообщает, что правило всё ещё включено и не менялось с момента срабатывания.
+func (e *Engine) unchanged(r loadedRule) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+
+	cur, err := e.app.Rules.Get(ctx, r.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.Info("rule_delayed_action_dropped", "rule_id", r.ID, "reason", "deleted")
+		} else {
+			slog.Error("rule_load_error", "rule_id", r.ID, "err", err)
+		}
+		return false
+	}
+	if !cur.Enabled || !cur.UpdatedAt.Equal(r.UpdatedAt) {
+		slog.Info("rule_delayed_action_dropped", "rule_id", r.ID, "reason", "disabled or updated")
+		return false
+	}
+	return true
+}
+
+func (e *Engine) dispatch(ruleID string, a Action, corr string) {
+	ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+
+	c, err := e.app.CreateCommand(ctx, app.CommandRequest{
+		DeviceID:      a.DeviceID,
+		Action:        a.Action,
+		Params:        a.Params,
+		Source:        Source(ruleID),
+		CorrelationID: corr,
+	})
+	if err != nil {
+		slog.Error("rule_action_error", "rule_id", ruleID, "device_id", a.DeviceID, "action", a.Action, "err", err)
+		return
+	}
+	slog.Info("rule_action", "rule_id", ruleID, "command_id", c.ID, "device_id", a.DeviceID, "action", a.Action)
+}
+
+// firedIn сообщает, срабатывало ли правило в этой цепочке; заодно чистит старые записи.
+func (e *Engine) firedIn(corr, ruleID string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	for k, at := range e.fired {
+		if now.Sub(at) > firedTTL {
+			delete(e.fired, k)
+		}
+	}
+	_, ok := e.fired[corr+"/"+ruleID]
+	return ok
+}
@@ -0,0 +1,197 @@
+package rules
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+	"github.com/ArthurGuatsaev/smarthome/internal/testutil"
+)
+
+type countingPub struct {
+	mu        sync.Mutex
+	n         int
+	published chan struct{}
+}
+
+func newCountingPub() *countingPub { return &countingPub{published: make(chan struct{}, 16)} }
+
+func (p *countingPub) Publish(ctx context.Context, topic string, qos byte, payload []byte) error {
+	p.mu.Lock()
+	p.n++
+	p.mu.Unlock()
+	select {
+	case p.published <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (p *countingPub) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.n
+}
+
+// logCapture пересылает записи slog в канал, чтобы тест мог дождаться события движка.
+type logCapture struct{ records chan slog.Record }
+
+func captureLogs(t *testing.T) *logCapture {
+	h := &logCapture{records: make(chan slog.Record, 64)}
+	prev := slog.Default()
+	slog.SetDefault(slog.New(h))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return h
+}
+
+func (h *logCapture) Enabled(context.Context, slog.Level) bool { return true }
+func (h *logCapture) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *logCapture) WithGroup(string) slog.Handler            { return h }
+
+func (h *logCapture) Handle(_ context.Context, r slog.Record) error {
+	select {
+	case h.records <- r.Clone():
+	default:
+	}
+	return nil
+}
+
+// wait ждёт запись msg и возвращает её атрибут key.
+func (h *logCapture) wait(t *testing.T, msg, key string) string {
+	t.Helper()
+	deadline := time.After(testutil.WaitTimeout)
+	for {
+		select {
+		case r := <-h.records:
+			if r.Message != msg {
+				continue
+			}
+			var v string
+			r.Attrs(func(a slog.Attr) bool {
+				if a.Key == key {
+					v = a.Value.String()
+				}
+				return true
+			})
+			return v
+		case <-deadline:
+			t.Fatalf("no %s log record", msg)
+		}
+	}
+}
+
+// runDelayed немедленно запускает ещё не выполненные отложенные действия правила.
+func (e *Engine) runDelayed(ruleID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, t := range e.delayed[ruleID] {
+		t.Reset(0)
+	}
+}
+
+func TestDelayedActionsAfterRuleChange(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(ctx context.Context, a *app.App, r storage.Rule) error
+		drop   string // причина в rule_delayed_action_dropped; пусто — действие выполняется
+	}{
+		{
+			name:   "unchanged",
+			change: func(context.Context, *app.App, storage.Rule) error { return nil },
+		},
+		{
+			name: "deleted",
+			change: func(ctx context.Context, a *app.App, r storage.Rule) error {
+				_, err := a.Rules.Delete(ctx, r.ID)
+				return err
+			},
+			drop: "deleted",
+		},
+		{
+			name: "disabled",
+			change: func(ctx context.Context, a *app.App, r storage.Rule) error {
+				r.Enabled = false
+				r.UpdatedAt = time.Now().UTC()
+				_, err := a.Rules.Update(ctx, r)
+				return err
+			},
+			drop: "disabled or updated",
+		},
+		{
+			name: "updated",
+			change: func(ctx context.Context, a *app.App, r storage.Rule) error {
+				r.Name = "renamed"
+				r.UpdatedAt = time.Now().UTC()
+				_, err := a.Rules.Update(ctx, r)
+				return err
+			},
+			drop: "disabled or updated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			pub := newCountingPub()
+			a := testutil.NewApp(t, pub)
+			logs := captureLogs(t)
+
+			now := time.Now().UTC()
+			for _, d := range []storage.Device{
+				{ID: "motion", Name: "motion", Type: "sensor", MQTTDeviceID: "motion", Capabilities: `["motion_sensor"]`, CreatedAt: now},
+				{ID: "lamp", Name: "lamp", Type: "light", MQTTDeviceID: "lamp", Capabilities: `["on_off"]`, CreatedAt: now},
+			} {
+				if _, err := a.CreateDevice(ctx, d); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// задержка заведомо больше теста: действие запускает runDelayed
+			spec := Spec{
+				Trigger: Trigger{Type: TriggerStateChanged, DeviceID: "motion"},
+				Actions: []Action{{DeviceID: "lamp", Action: "turn_off", Delay: "1h"}},
+			}
+			r := storage.Rule{ID: "r1", Name: "off later", Enabled: true, CreatedAt: now, UpdatedAt: now}
+			spec.Encode(&r)
+			if err := a.Rules.Create(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+
+			e := New(a)
+			defer e.stop()
+			sub := a.Events.Subscribe(16, func(ev app.Event) bool { return ev.Type == app.EventDeviceStateChanged })
+			defer sub.Close()
+
+			if err := a.HandleTelemetry(ctx, testutil.HomeID, "motion", []byte(`{"motion":true}`)); err != nil {
+				t.Fatal(err)
+			}
+			e.onStateChanged(ctx, <-sub.Events())
+			if err := tt.change(ctx, a, r); err != nil {
+				t.Fatal(err)
+			}
+			e.runDelayed(r.ID)
+
+			if tt.drop == "" {
+				select {
+				case <-pub.published:
+				case <-time.After(testutil.WaitTimeout):
+					t.Fatal("delayed action was not published")
+				}
+				if got := pub.count(); got != 1 {
+					t.Fatalf("published %d commands, want 1", got)
+				}
+				return
+			}
+			if reason := logs.wait(t, "rule_delayed_action_dropped", "reason"); reason != tt.drop {
+				t.Fatalf("dropped with reason %q, want %q", reason, tt.drop)
+			}
+			if got := pub.count(); got != 0 {
+				t.Fatalf("published %d commands, want 0", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+const (
+	TriggerStateChanged = "state_changed"
+	TriggerTimer        = "timer"
+)
+
+// Минимальный период таймера, чтобы правило не заспамило устройства
+const minTimerEvery = time.Second
+
+var ErrInvalidRule = errors.New("invalid rule")
+
+type Trigger struct {
+	Type     string `json:"type"`               // state_changed | timer
+	DeviceID string `json:"deviceId,omitempty"` // для state_changed
+	Every    string `json:"every,omitempty"`    // для timer: "30s", "5m"
+}
+
+type Condition struct {
+	DeviceID string `json:"deviceId,omitempty"` // по умолчанию — устройство триггера
+	Field    string `json:"field"`              // путь в state_json: "motion", "climate.temp"
+	Op       string `json:"op"`                 // eq|ne|gt|gte|lt|lte
+	Value    any    `json:"value"`
+}
+
+type Action struct {
+	DeviceID string          `json:"deviceId"`
+	Action   string          `json:"action"`
+	Params   json.RawMessage `json:"params,omitempty"`
+	Delay    string          `json:"delay,omitempty"` // "2m": выполнить позже
+}
+
+// Spec — разобранное содержимое правила (trigger_json, conditions_json, actions_json).
+type Spec struct {
+	Trigger    Trigger     `json:"trigger"`
+	Conditions []Condition `json:"conditions"`
+	Actions    []Action    `json:"actions"`
+}
+
+func ParseSpec(r storage.Rule) (Spec, error) {
+	var s Spec
+	if err := json.Unmarshal([]byte(r.TriggerJSON), &s.Trigger); err != nil {
+		return Spec{}, fmt.Errorf("rule %s trigger: %w", r.ID, err)
+	}
+	if err := json.Unmarshal([]byte(r.ConditionsJSON), &s.Conditions); err != nil {
+		return Spec{}, fmt.Errorf("rule %s conditions: %w", r.ID, err)
+	}
+	if err := json.Unmarshal([]byte(r.ActionsJSON), &s.Actions); err != nil {
+		return Spec{}, fmt.Errorf("rule %s actions: %w", r.ID, err)
+	}
+	return s, nil
+}
+
+// Encode раскладывает Spec по JSON-колонкам storage.Rule.
+func (s Spec) Encode(r *storage.Rule) {
+	t, _ := json.Marshal(s.Trigger)
+	c, _ := json.Marshal(nonNil(s.Conditions))
+	a, _ := json.Marshal(nonNil(s.Actions))
+	r.TriggerJSON, r.ConditionsJSON, r.ActionsJSON = string(t), string(c), string(a)
+}
+
+func (s Spec) Validate() error {
+	switch s.Trigger.Type {
+	case TriggerStateChanged:
+		if s.Trigger.DeviceID == "" {
+			return fmt.Errorf("%w: trigger.deviceId required", ErrInvalidRule)
+		}
+	case TriggerTimer:
+		d, err := time.ParseDuration(s.Trigger.Every)
+		if err != nil || d < minTimerEvery {
+			return fmt.Errorf("%w: trigger.every must be a duration >= %s", ErrInvalidRule, minTimerEvery)
+		}
+	default:
+		return fmt.Errorf("%w: trigger.type must be %s or %s", ErrInvalidRule, TriggerStateChanged, TriggerTimer)
+	}
+
+	for i, c := range s.Conditions {
+		if c.Field == "" {
+			return fmt.Errorf("%w: conditions[%d].field required", ErrInvalidRule, i)
+		}
+		if s.Trigger.Type == TriggerTimer && c.DeviceID == "" {
+			return fmt.Errorf("%w: conditions[%d].deviceId required for timer rules", ErrInvalidRule, i)
+		}
+		switch c.Op {
+		case "eq", "ne":
+		case "gt", "gte", "lt", "lte":
+			if _, ok := c.Value.(float64); !ok {
+				return fmt.Errorf("%w: conditions[%d].value must be a number for %s", ErrInvalidRule, i, c.Op)
+			}
+		default:
+			return fmt.Errorf("%w: conditions[%d].op must be one of eq, ne, gt, gte, lt, lte", ErrInvalidRule, i)
+		}
+	}
+
+	if len(s.Actions) == 0 {
+		return fmt.Errorf("%w: at least one action required", ErrInvalidRule)
+	}
+	for i, a := range s.Actions {
+		if a.DeviceID == "" || a.Action == "" {
+			return fmt.Errorf("%w: actions[%d].deviceId and action required", ErrInvalidRule, i)
+		}
+		if a.Delay != "" {
+			if d, err := time.ParseDuration(a.Delay); err != nil || d < 0 {
+				return fmt.Errorf("%w: actions[%d].delay must be a duration", ErrInvalidRule, i)
+			}
+		}
+		if p := bytes.TrimSpace(a.Params); len(p) > 0 && !bytes.Equal(p, []byte("null")) && p[0] != '{' {
+			return fmt.Errorf("%w: actions[%d].params must be an object", ErrInvalidRule, i)
+		}
+	}
+	return nil
+}
+
+// DeviceIDs — все устройства, на которые ссылается правило.
+func (s Spec) DeviceIDs() []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	add(s.Trigger.DeviceID)
+	for _, c := range s.Conditions {
+		add(c.DeviceID)
+	}
+	for _, a := range s.Actions {
+		add(a.DeviceID)
+	}
+	return out
+}
+
+// Match проверяет условие на состоянии устройства (декодированный state_json).
+func (c Condition) Match(state map[string]any) bool {
+	v, ok := lookup(state, c.Field)
+	if !ok {
+		return c.Op == "ne"
+	}
+
+	switch c.Op {
+	case "eq":
+		return equal(v, c.Value)
+	case "ne":
+		return !equal(v, c.Value)
+	}
+
+	got, ok1 := v.(float64)
+	want, ok2 := c.Value.(float64)
+	if !ok1 || !ok2 {
+		return false
+	}
+	switch c.Op {
+	case "gt":
+		return got > want
+	case "gte":
+		return got >= want
+	case "lt":
+		return got < want
+	case "lte":
+		return got <= want
+	}
+	return false
+}
+
+func lookup(state map[string]any, path string) (any, bool) {
+	var cur any = state
+	for _, key := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// equal сравнивает скаляры JSON (числа — как float64).
+func equal(a, b any) bool {
+	switch av := a.(type) {
+	case float64, string, bool, nil:
+		return av == b
+	}
+	return false
+}
+
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
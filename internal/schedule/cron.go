@@ -0,0 +1,193 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron — разобранное cron-выражение из 5 полей: минута, час, день месяца,
+// месяц, день недели. Поддерживаются *, списки, диапазоны, шаги (*/15, 1-5/2),
+// имена месяцев и дней (jan, mon) и макросы @hourly, @daily, @weekly, @monthly, @yearly.
+type Cron struct {
+	minute, hour, dom, month, dow uint64 // битовые маски допустимых значений
+	domAny, dowAny                bool   // поле было "*": см. dayMatches
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = cronField{min: 0, max: 59}
+	hourField   = cronField{min: 0, max: 23}
+	domField    = cronField{min: 1, max: 31}
+	monthField  = cronField{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 — тоже воскресенье
+	dowField = cronField{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Горизонт поиска следующего срабатывания (например, "0 0 30 2 *" не сработает никогда)
+const cronSearchYears = 5
+
+func ParseCron(expr string) (Cron, error) {
+	expr = strings.TrimSpace(strings.ToLower(expr))
+	if m, ok := cronMacros[expr]; ok {
+		expr = m
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Cron{}, fmt.Errorf("cron: want 5 fields, got %d", len(fields))
+	}
+
+	var c Cron
+	var err error
+	if c.minute, err = minuteField.parse(fields[0]); err != nil {
+		return Cron{}, fmt.Errorf("cron minute: %w", err)
+	}
+	if c.hour, err = hourField.parse(fields[1]); err != nil {
+		return Cron{}, fmt.Errorf("cron hour: %w", err)
+	}
+	if c.dom, err = domField.parse(fields[2]); err != nil {
+		return Cron{}, fmt.Errorf("cron day of month: %w", err)
+	}
+	if c.month, err = monthField.parse(fields[3]); err != nil {
+		return Cron{}, fmt.Errorf("cron month: %w", err)
+	}
+	if c.dow, err = dowField.parse(fields[4]); err != nil {
+		return Cron{}, fmt.Errorf("cron day of week: %w", err)
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAny = fields[2] == "*" || fields[2] == "?"
+	c.dowAny = fields[4] == "*" || fields[4] == "?"
+	return c, nil
+}
+
+// Next возвращает первое срабатывание строго после after по часам loc;
+// false — в ближайшие годы срабатываний нет.
+//
+// Переход на летнее время: несуществующие минуты пропускаются,
+// повторяющийся час срабатывает один раз.
+func (c Cron) Next(after time.Time, loc *time.Location) (time.Time, bool) {
+	t := after.In(loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case c.month&(1<<uint(m)) == 0:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			next := time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+			if !next.After(t) {
+				// повтор часа при переводе часов назад
+				next = t.Truncate(time.Hour).Add(time.Hour)
+			}
+			t = next
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		case !time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc).Equal(t):
+			// второй проход того же времени после перевода часов назад
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// dayMatches — как в классическом cron: если ограничены и день месяца,
+// и день недели, достаточно совпадения любого из них.
+func (c Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+func (f cronField) parse(s string) (uint64, error) {
+	var mask uint64
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, step := f.min, f.max, 1
+
+		rng := part
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			step, rng = n, part[:i]
+		}
+
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("bad range %q", rng)
+			}
+		default:
+			v, err := f.value(rng)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			// "5/10" — с 5 до конца с шагом 10
+			if !strings.Contains(part, "/") {
+				hi = v
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+	return mask, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[s]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %q out of range %d-%d", s, f.min, f.max)
+	}
+	return v, nil
+}
@@ -0,0 +1,161 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		expr  string
+		loc   *time.Location
+		after string
+		want  []string // последовательные срабатывания; пусто — срабатываний нет
+	}{
+		{
+			name:  "dst gap skips missing minute",
+			expr:  "30 2 * * *",
+			loc:   ny,
+			after: "2026-03-08T00:00:00-05:00",
+			want:  []string{"2026-03-09T02:30:00-04:00"},
+		},
+		{
+			name:  "dst gap hourly",
+			expr:  "0 * * * *",
+			loc:   ny,
+			after: "2026-03-08T00:30:00-05:00",
+			want:  []string{"2026-03-08T01:00:00-05:00", "2026-03-08T03:00:00-04:00"},
+		},
+		{
+			name:  "repeated hour fires once",
+			expr:  "30 1 * * *",
+			loc:   ny,
+			after: "2026-11-01T00:00:00-04:00",
+			want:  []string{"2026-11-01T01:30:00-04:00", "2026-11-02T01:30:00-05:00"},
+		},
+		{
+			name:  "repeated hour hourly",
+			expr:  "@hourly",
+			loc:   ny,
+			after: "2026-11-01T00:30:00-04:00",
+			want:  []string{"2026-11-01T01:00:00-04:00", "2026-11-01T02:00:00-05:00"},
+		},
+		{
+			name:  "day of month or day of week",
+			expr:  "0 12 1 * 0",
+			loc:   time.UTC,
+			after: "2026-09-30T00:00:00Z",
+			want:  []string{"2026-10-01T12:00:00Z", "2026-10-04T12:00:00Z", "2026-10-11T12:00:00Z"},
+		},
+		{
+			name:  "day of week only",
+			expr:  "0 9 * * mon-fri",
+			loc:   time.UTC,
+			after: "2026-10-16T10:00:00Z",
+			want:  []string{"2026-10-19T09:00:00Z", "2026-10-20T09:00:00Z"},
+		},
+		{
+			name:  "step from start value",
+			expr:  "5/10 * * * *",
+			loc:   time.UTC,
+			after: "2026-10-18T10:40:00Z",
+			want:  []string{"2026-10-18T10:45:00Z", "2026-10-18T10:55:00Z", "2026-10-18T11:05:00Z"},
+		},
+		{
+			name:  "range with step",
+			expr:  "0 8-18/4 * * *",
+			loc:   time.UTC,
+			after: "2026-10-18T13:00:00Z",
+			want:  []string{"2026-10-18T16:00:00Z", "2026-10-19T08:00:00Z"},
+		},
+		{
+			name:  "7 is sunday",
+			expr:  "0 9 * * 7",
+			loc:   time.UTC,
+			after: "2026-10-16T10:00:00Z",
+			want:  []string{"2026-10-18T09:00:00Z", "2026-10-25T09:00:00Z"},
+		},
+		{
+			name:  "range up to 7",
+			expr:  "0 9 * * 5-7",
+			loc:   time.UTC,
+			after: "2026-10-16T10:00:00Z",
+			want:  []string{"2026-10-17T09:00:00Z", "2026-10-18T09:00:00Z", "2026-10-23T09:00:00Z"},
+		},
+		{
+			name:  "month names",
+			expr:  "0 0 1 jan,jul *",
+			loc:   time.UTC,
+			after: "2026-10-18T00:00:00Z",
+			want:  []string{"2027-01-01T00:00:00Z", "2027-07-01T00:00:00Z"},
+		},
+		{
+			name:  "leap day",
+			expr:  "0 0 29 2 *",
+			loc:   time.UTC,
+			after: "2026-01-01T00:00:00Z",
+			want:  []string{"2028-02-29T00:00:00Z"},
+		},
+		{
+			name:  "never",
+			expr:  "0 0 30 2 *",
+			loc:   time.UTC,
+			after: "2026-01-01T00:00:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			at, err := time.Parse(time.RFC3339, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range tt.want {
+				next, ok := c.Next(at, tt.loc)
+				if !ok {
+					t.Fatalf("run %d: no next, want %s", i, want)
+				}
+				if got := next.Format(time.RFC3339); got != want {
+					t.Fatalf("run %d: got %s, want %s", i, got, want)
+				}
+				at = next
+			}
+			if len(tt.want) == 0 {
+				if next, ok := c.Next(at, tt.loc); ok {
+					t.Fatalf("got %s, want no runs", next.Format(time.RFC3339))
+				}
+			}
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@every 5m",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q): want error", expr)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+const (
+	// Как часто проверять наступившие срабатывания
+	tick = time.Second
+	// Срабатывание, опоздавшее сильнее (процесс не работал), не выполняется,
+	// а записывается как missed: утренний свет в обед никому не нужен
+	misfireGrace = time.Minute
+	// Таймаут на выполнение одного срабатывания
+	fireTimeout = 10 * time.Second
+)
+
+// Source — значение commands.source для команд расписания.
+func Source(scheduleID string) string { return "schedule:" + scheduleID }
+
+// Scheduler выполняет расписания: раз в tick забирает наступившие срабатывания
+// и отправляет команды тем же путём, что и API (app.CreateCommand, app.ActivateScene).
+//
+// Следующее срабатывание хранится в schedules.next_run_at и переносится CAS-ом
+// до выполнения, поэтому рестарт или второй процесс не выполнят его повторно.
+type Scheduler struct {
+	app     *app.App
+	planner Planner
+}
+
+func New(a *app.App, p Planner) *Scheduler {
+	return &Scheduler{app: a, planner: p}
+}
+
+// Run блокируется до отмены ctx.
+func (s *Scheduler) Run(ctx context.Context) {
+	t := time.NewTicker(tick)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			s.onTick(ctx, now.UTC())
+		}
+	}
+}
+
+func (s *Scheduler) onTick(ctx context.Context, now time.Time) {
+	due, err := s.app.Schedules.ListDue(ctx, now)
+	if err != nil {
+		slog.Error("schedules_load_error", "err", err)
+		return
+	}
+	for _, sc := range due {
+		s.fire(ctx, sc, now)
+	}
+}
+
+func (s *Scheduler) fire(ctx context.Context, sc storage.Schedule, now time.Time) {
+	spec, err := ParseSpec(sc)
+	if err != nil {
+		slog.Error("schedule_parse_error", "schedule_id", sc.ID, "err", err)
+		return
+	}
+
+	// следующее — после now, а не после due: пропущенные за простой срабатывания не догоняем
+	var next *time.Time
+	if at, ok := s.planner.Next(spec, now); ok {
+		next = &at
+	}
+	claimed, err := s.app.Schedules.Claim(ctx, sc.ID, *sc.NextRunAt, next, now)
+	if err != nil {
+		slog.Error("schedule_claim_error", "schedule_id", sc.ID, "err", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	res := storage.Schedule{ID: sc.ID, LastStatus: storage.ScheduleRunOK}
+	if late := now.Sub(*sc.NextRunAt); late > misfireGrace {
+		res.LastStatus = storage.ScheduleRunMissed
+		res.LastError = "missed by " + late.Truncate(time.Second).String()
+		slog.Warn("schedule_missed", "schedule_id", sc.ID, "due", *sc.NextRunAt, "late", late.String())
+	} else {
+		s.dispatch(ctx, sc.ID, spec.Target, &res)
+	}
+
+	if err := s.app.Schedules.SetResult(ctx, res); err != nil {
+		slog.Error("schedule_result_error", "schedule_id", sc.ID, "err", err)
+	}
+}
+
+func (s *Scheduler) dispatch(ctx context.Context, scheduleID string, t Target, res *storage.Schedule) {
+	ctx, cancel := context.WithTimeout(ctx, fireTimeout)
+	defer cancel()
+
+	if t.SceneID != "" {
+		run, err := s.app.ActivateScene(ctx, t.SceneID, Source(scheduleID), nil)
+		if err != nil {
+			res.LastStatus, res.LastError = storage.ScheduleRunError, err.Error()
+			slog.Error("schedule_scene_error", "schedule_id", scheduleID, "scene_id", t.SceneID, "err", err)
+			return
+		}
+		res.LastSceneRunID = run.ID
+		slog.Info("schedule_scene", "schedule_id", scheduleID, "scene_id", t.SceneID, "run_id", run.ID)
+		return
+	}
+
+	c, err := s.app.CreateCommand(ctx, app.CommandRequest{
+		DeviceID: t.DeviceID,
+		Action:   t.Action,
+		Params:   t.Params,
+		Source:   Source(scheduleID),
+	})
+	if err != nil {
+		res.LastStatus, res.LastError = storage.ScheduleRunError, err.Error()
+		if errors.Is(err, app.ErrPublish) {
+			res.LastCommandID = c.ID
+		}
+		slog.Error("schedule_command_error", "schedule_id", scheduleID, "device_id", t.DeviceID, "action", t.Action, "err", err)
+		return
+	}
+	res.LastCommandID = c.ID
+	slog.Info("schedule_command", "schedule_id", scheduleID, "command_id", c.ID, "device_id", t.DeviceID, "action", t.Action)
+}
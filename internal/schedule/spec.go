@@ -0,0 +1,158 @@
+package schedule
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+const (
+	TriggerCron = "cron"
+	TriggerSun  = "sun"
+)
+
+// Сдвиг от восхода/заката не больше полусуток
+const maxSunOffset = 12 * time.Hour
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+type Trigger struct {
+	Type   string `json:"type"`             // cron | sun
+	Cron   string `json:"cron,omitempty"`   // для cron: "0 7 * * mon-fri"
+	Event  string `json:"event,omitempty"`  // для sun: sunrise | sunset
+	Offset string `json:"offset,omitempty"` // для sun: "-30m" — за полчаса до события
+}
+
+// Target — что делает расписание: команда устройству или запуск сцены.
+type Target struct {
+	DeviceID string          `json:"deviceId,omitempty"`
+	Action   string          `json:"action,omitempty"`
+	Params   json.RawMessage `json:"params,omitempty"`
+	SceneID  string          `json:"sceneId,omitempty"`
+}
+
+// Spec — разобранное содержимое расписания (timezone, trigger_json, target_json).
+type Spec struct {
+	Timezone string  `json:"timezone,omitempty"` // IANA; пусто — TIMEZONE из конфига
+	Trigger  Trigger `json:"trigger"`
+	Target   Target  `json:"target"`
+}
+
+func ParseSpec(s storage.Schedule) (Spec, error) {
+	spec := Spec{Timezone: s.Timezone}
+	if err := json.Unmarshal([]byte(s.TriggerJSON), &spec.Trigger); err != nil {
+		return Spec{}, fmt.Errorf("schedule %s trigger: %w", s.ID, err)
+	}
+	if err := json.Unmarshal([]byte(s.TargetJSON), &spec.Target); err != nil {
+		return Spec{}, fmt.Errorf("schedule %s target: %w", s.ID, err)
+	}
+	return spec, nil
+}
+
+// Encode раскладывает Spec по колонкам storage.Schedule.
+func (s Spec) Encode(sc *storage.Schedule) {
+	t, _ := json.Marshal(s.Trigger)
+	g, _ := json.Marshal(s.Target)
+	sc.Timezone, sc.TriggerJSON, sc.TargetJSON = s.Timezone, string(t), string(g)
+}
+
+// Planner считает срабатывания: часовой пояс по умолчанию и координаты дома из конфига.
+type Planner struct {
+	Location    *time.Location
+	Coordinates *Coordinates // nil — sunrise/sunset-расписания недоступны
+}
+
+// Zone — часовой пояс расписания.
+func (p Planner) Zone(s Spec) *time.Location {
+	if s.Timezone != "" {
+		if loc, err := time.LoadLocation(s.Timezone); err == nil {
+			return loc
+		}
+	}
+	if p.Location == nil {
+		return time.Local
+	}
+	return p.Location
+}
+
+// Validate проверяет триггер и форму цели; существование устройства или сцены — на вызывающем.
+func (p Planner) Validate(s Spec) error {
+	if s.Timezone != "" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, s.Timezone)
+		}
+	}
+
+	switch s.Trigger.Type {
+	case TriggerCron:
+		if _, err := ParseCron(s.Trigger.Cron); err != nil {
+			return fmt.Errorf("%w: trigger.cron: %v", ErrInvalidSchedule, err)
+		}
+	case TriggerSun:
+		if s.Trigger.Event != SunEventSunrise && s.Trigger.Event != SunEventSunset {
+			return fmt.Errorf("%w: trigger.event must be %s or %s", ErrInvalidSchedule, SunEventSunrise, SunEventSunset)
+		}
+		if s.Trigger.Offset != "" {
+			d, err := time.ParseDuration(s.Trigger.Offset)
+			if err != nil || d < -maxSunOffset || d > maxSunOffset {
+				return fmt.Errorf("%w: trigger.offset must be a duration within ±%s", ErrInvalidSchedule, maxSunOffset)
+			}
+		}
+		if p.Coordinates == nil {
+			return fmt.Errorf("%w: sun triggers need LATITUDE and LONGITUDE configured", ErrInvalidSchedule)
+		}
+	default:
+		return fmt.Errorf("%w: trigger.type must be %s or %s", ErrInvalidSchedule, TriggerCron, TriggerSun)
+	}
+
+	t := s.Target
+	switch {
+	case t.SceneID != "" && (t.DeviceID != "" || t.Action != ""):
+		return fmt.Errorf("%w: target must be either a command or a scene", ErrInvalidSchedule)
+	case t.SceneID == "" && (t.DeviceID == "" || t.Action == ""):
+		return fmt.Errorf("%w: target.deviceId and action (or target.sceneId) required", ErrInvalidSchedule)
+	}
+	if p := bytes.TrimSpace(t.Params); len(p) > 0 && !bytes.Equal(p, []byte("null")) && p[0] != '{' {
+		return fmt.Errorf("%w: target.params must be an object", ErrInvalidSchedule)
+	}
+	return nil
+}
+
+// Next — первое срабатывание строго после after; false — срабатываний не предвидится
+// (cron на 30 февраля, полярный день для sunset).
+func (p Planner) Next(s Spec, after time.Time) (time.Time, bool) {
+	loc := p.Zone(s)
+	switch s.Trigger.Type {
+	case TriggerCron:
+		c, err := ParseCron(s.Trigger.Cron)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return c.Next(after, loc)
+	case TriggerSun:
+		if p.Coordinates == nil {
+			return time.Time{}, false
+		}
+		offset, _ := time.ParseDuration(s.Trigger.Offset)
+		return NextSunEvent(s.Trigger.Event, offset, after, loc, *p.Coordinates)
+	}
+	return time.Time{}, false
+}
+
+// Upcoming — до n ближайших срабатываний после after.
+func (p Planner) Upcoming(s Spec, after time.Time, n int) []time.Time {
+	out := []time.Time{}
+	for range n {
+		next, ok := p.Next(s, after)
+		if !ok {
+			break
+		}
+		out = append(out, next)
+		after = next
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package schedule
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	SunEventSunrise = "sunrise"
+	SunEventSunset  = "sunset"
+)
+
+// Coordinates — точка для расчёта восхода и заката (градусы, восток и север положительны).
+type Coordinates struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Горизонт поиска дня с восходом/закатом (полярная ночь или день длится до полугода)
+const sunSearchDays = 370
+
+// SunTimes считает восход и заход для календарного дня date по часам loc
+// (уравнение восхода, точность ~1 мин). ok=false — в этот день солнце не
+// восходит или не заходит.
+func SunTimes(date time.Time, loc *time.Location, c Coordinates) (rise, set time.Time, ok bool) {
+	y, m, d := date.In(loc).Date()
+	// дней от J2000.0 до этой даты
+	n := float64(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix())/86400 + 2440587.5 - 2451545.0
+	n = math.Ceil(n + 0.0008)
+
+	jStar := n - c.Longitude/360
+	mean := math.Mod(357.5291+0.98560028*jStar, 360)
+	center := 1.9148*sin(mean) + 0.02*sin(2*mean) + 0.0003*sin(3*mean)
+	lambda := math.Mod(mean+center+180+102.9372, 360)
+	transit := 2451545.0 + jStar + 0.0053*sin(mean) - 0.0069*sin(2*lambda)
+
+	sinDecl := sin(lambda) * sin(23.4397)
+	cosDecl := math.Cos(math.Asin(sinDecl))
+	// -0.833° — рефракция и радиус диска
+	cosHour := (sin(-0.833) - sin(c.Latitude)*sinDecl) / (math.Cos(c.Latitude*math.Pi/180) * cosDecl)
+	if cosHour < -1 || cosHour > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hour := math.Acos(cosHour) * 180 / math.Pi
+
+	return julianToTime(transit - hour/360).In(loc), julianToTime(transit + hour/360).In(loc), true
+}
+
+// NextSunEvent возвращает первое событие (восход или заход) со сдвигом offset
+// строго после after; false — события нет в пределах года.
+func NextSunEvent(event string, offset time.Duration, after time.Time, loc *time.Location, c Coordinates) (time.Time, bool) {
+	// со вчерашнего дня: отрицательный offset может увести событие на день назад
+	day := after.In(loc).AddDate(0, 0, -1)
+	for range sunSearchDays {
+		rise, set, ok := SunTimes(day, loc, c)
+		if ok {
+			at := set
+			if event == SunEventSunrise {
+				at = rise
+			}
+			// до секунды: как и у cron, срабатывание — в начале секунды
+			if at = at.Add(offset).Truncate(time.Second); at.After(after) {
+				return at, true
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}, false
+}
+
+func julianToTime(j float64) time.Time {
+	secs := (j - 2440587.5) * 86400
+	return time.Unix(0, int64(secs*1e9)).UTC()
+}
+
+func sin(deg float64) float64 { return math.Sin(deg * math.Pi / 180) }
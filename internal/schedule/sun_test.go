@@ -0,0 +1,142 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+var tromso = Coordinates{Latitude: 69.65, Longitude: 18.96}
+
+func TestSunTimes(t *testing.T) {
+	msk, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moscow := Coordinates{Latitude: 55.7558, Longitude: 37.6173}
+
+	tests := []struct {
+		name string
+		date string
+		loc  *time.Location
+		c    Coordinates
+		ok   bool
+		rise string // ЧЧ:ММ по loc, допуск — 2 минуты
+		set  string
+	}{
+		{name: "moscow summer", date: "2026-06-21", loc: msk, c: moscow, ok: true, rise: "03:44", set: "21:18"},
+		{name: "moscow winter", date: "2026-12-21", loc: msk, c: moscow, ok: true, rise: "08:57", set: "15:57"},
+		{name: "polar day", date: "2026-06-21", loc: oslo, c: tromso},
+		{name: "polar night", date: "2026-12-21", loc: oslo, c: tromso},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day, err := time.ParseInLocation(time.DateOnly, tt.date, tt.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rise, set, ok := SunTimes(day, tt.loc, tt.c)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			checkClock(t, "sunrise", rise, day, tt.rise)
+			checkClock(t, "sunset", set, day, tt.set)
+		})
+	}
+}
+
+func checkClock(t *testing.T, what string, got, day time.Time, want string) {
+	t.Helper()
+	clock, err := time.ParseInLocation(time.DateOnly+" 15:04", day.Format(time.DateOnly)+" "+want, day.Location())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := got.Sub(clock).Abs(); d > 2*time.Minute {
+		t.Fatalf("%s = %s, want about %s", what, got.Format(time.DateTime), clock.Format(time.DateTime))
+	}
+}
+
+func TestNextSunEvent(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	losAngeles := Coordinates{Latitude: 34.05, Longitude: -118.24}
+
+	tests := []struct {
+		name   string
+		event  string
+		offset time.Duration
+		after  string // RFC3339
+		loc    *time.Location
+		c      Coordinates
+		// ожидаемое событие лежит в [from, to)
+		from, to string
+	}{
+		{
+			name: "same day sunset", event: SunEventSunset,
+			after: "2026-10-18T12:00:00-07:00", loc: la, c: losAngeles,
+			from: "2026-10-18T18:00:00-07:00", to: "2026-10-18T18:30:00-07:00",
+		},
+		{
+			name: "sunset passed, next day", event: SunEventSunset, offset: -30 * time.Minute,
+			after: "2026-10-18T20:00:00-07:00", loc: la, c: losAngeles,
+			from: "2026-10-19T17:30:00-07:00", to: "2026-10-19T18:00:00-07:00",
+		},
+		{
+			name: "negative offset earlier the same day", event: SunEventSunrise, offset: -2 * time.Hour,
+			after: "2026-10-18T04:00:00-07:00", loc: la, c: losAngeles,
+			from: "2026-10-18T04:45:00-07:00", to: "2026-10-18T05:15:00-07:00",
+		},
+		{
+			name: "polar day: first sunset after midnight sun", event: SunEventSunset,
+			after: "2026-06-21T12:00:00+02:00", loc: oslo, c: tromso,
+			from: "2026-07-20T00:00:00+02:00", to: "2026-08-01T00:00:00+02:00",
+		},
+		{
+			name: "polar night: first sunrise after polar night", event: SunEventSunrise,
+			after: "2026-12-21T12:00:00+01:00", loc: oslo, c: tromso,
+			from: "2027-01-10T00:00:00+01:00", to: "2027-01-20T00:00:00+01:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := mustTime(t, tt.after)
+			got, ok := NextSunEvent(tt.event, tt.offset, after, tt.loc, tt.c)
+			if !ok {
+				t.Fatal("no event")
+			}
+			from, to := mustTime(t, tt.from), mustTime(t, tt.to)
+			if got.Before(from) || !got.Before(to) {
+				t.Fatalf("got %s, want in [%s, %s)", got.Format(time.RFC3339), tt.from, tt.to)
+			}
+			if !got.After(after) {
+				t.Fatalf("got %s, not after %s", got.Format(time.RFC3339), tt.after)
+			}
+			if got.Nanosecond() != 0 {
+				t.Fatalf("got %s, want whole seconds", got.Format(time.RFC3339Nano))
+			}
+		})
+	}
+}
+
+func mustTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
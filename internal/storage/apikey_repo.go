@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type APIKeyRepo struct{ db queryer }
+
+func NewAPIKeyRepo(db *sql.DB) *APIKeyRepo { return &APIKeyRepo{db: instrument(db)} }
+
+const apiKeyColumns = `id, name, key_hash, scopes_json, created_at, revoked_at`
+
+func (r *APIKeyRepo) Create(ctx context.Context, k APIKey) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO api_keys(id, name, key_hash, scopes_json, created_at, revoked_at)
+		VALUES(?, ?, ?, ?, ?, NULL)
+	`, k.ID, k.Name, k.KeyHash, k.ScopesJSON, formatTime(k.CreatedAt))
+	return err
+}
+
+// GetActiveByHash ищет неотозванный ключ по хэшу.
+func (r *APIKeyRepo) GetActiveByHash(ctx context.Context, hash string) (APIKey, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL
+	`, hash)
+	return scanAPIKey(row)
+}
+
+func (r *APIKeyRepo) List(ctx context.Context) ([]APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+// Revoke отзывает ключ; false — ключа нет или он уже отозван.
+func (r *APIKeyRepo) Revoke(ctx context.Context, id string, at time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE api_keys SET revoked_at = ?
+		WHERE id = ? AND revoked_at IS NULL
+	`, formatTime(at), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var k APIKey
+	var created string
+	var revoked sql.NullString
+	if err := row.Scan(&k.ID, &k.Name, &k.KeyHash, &k.ScopesJSON, &created, &revoked); err != nil {
+		return APIKey{}, err
+	}
+	k.CreatedAt = parseTime(created)
+	if revoked.Valid {
+		at := parseTime(revoked.String)
+		k.RevokedAt = &at
+	}
+	return k, nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+type AuditRepo struct{ db queryer }
+
+func NewAuditRepo(db *sql.DB) *AuditRepo { return &AuditRepo{db: instrument(db)} }
+
+func (r *AuditRepo) Append(ctx context.Context, e AuditEntry) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO audit_log(at, actor, request_id, method, route, resource, status, outcome)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+	`, formatTime(e.At), e.Actor, e.RequestID, e.Method, e.Route, e.Resource, e.Status, e.Outcome)
+	return err
+}
+
+// List возвращает записи от новых к старым.
+func (r *AuditRepo) List(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	var where []string
+	var args []any
+	if !f.From.IsZero() {
+		where = append(where, "at >= ?")
+		args = append(args, formatTime(f.From))
+	}
+	if !f.To.IsZero() {
+		where = append(where, "at < ?")
+		args = append(args, formatTime(f.To))
+	}
+	if f.Actor != "" {
+		where = append(where, "actor = ?")
+		args = append(args, f.Actor)
+	}
+	if f.BeforeID > 0 {
+		where = append(where, "id < ?")
+		args = append(args, f.BeforeID)
+	}
+
+	q := `SELECT id, at, actor, request_id, method, route, resource, status, outcome FROM audit_log`
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY id DESC LIMIT ?"
+	args = append(args, f.Limit)
+
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var at string
+		if err := rows.Scan(&e.ID, &at, &e.Actor, &e.RequestID, &e.Method, &e.Route, &e.Resource, &e.Status, &e.Outcome); err != nil {
+			return nil, err
+		}
+		e.At = parseTime(at)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -3,67 +3,226 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"time"
 )
 
-type CommandRepo struct{ db *sql.DB }
+type CommandRepo struct{ db queryer }
 
-func NewCommandRepo(db *sql.DB) *CommandRepo { return &CommandRepo{db: db} }
+func NewCommandRepo(db *sql.DB) *CommandRepo { return &CommandRepo{db: instrument(db)} }
+
+const commandColumns = `id, device_id, action, params_json, status, error, created_at, acked_at, source, correlation_id, group_command_id`
 
 func (r *CommandRepo) Create(ctx context.Context, c Command) error {
 	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO commands(id, device_id, action, params_json, status, error, created_at, acked_at)
-		VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO commands(id, device_id, action, params_json, status, error, created_at, acked_at, source, correlation_id, group_command_id)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, c.ID, c.DeviceID, c.Action, c.ParamsJSON, c.Status, c.Error,
-		c.CreatedAt.UTC().Format(time.RFC3339Nano),
+		formatTime(c.CreatedAt),
 		nil,
+		c.Source, c.CorrelationID, c.GroupCommandID,
 	)
 	return err
 }
 
 func (r *CommandRepo) Get(ctx context.Context, id string) (Command, error) {
 	row := r.db.QueryRowContext(ctx, `
-		SELECT id, device_id, action, params_json, status, error, created_at, acked_at
+		SELECT `+commandColumns+`
 		FROM commands WHERE id = ?
 	`, id)
+	return scanCommand(row)
+}
 
-	var c Command
-	var created string
-	var acked sql.NullString
+// ListPendingBefore возвращает pending-команды, созданные раньше before.
+func (r *CommandRepo) ListPendingBefore(ctx context.Context, before time.Time) ([]Command, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+commandColumns+`
+		FROM commands
+		WHERE status = 'pending' AND created_at < ?
+		ORDER BY created_at
+	`, formatTime(before))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	if err := row.Scan(&c.ID, &c.DeviceID, &c.Action, &c.ParamsJSON, &c.Status, &c.Error, &created, &acked); err != nil {
-		return Command{}, err
+	var out []Command
+	for rows.Next() {
+		c, err := scanCommand(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
 	}
+	return out, rows.Err()
+}
 
-	ct, _ := time.Parse(time.RFC3339Nano, created)
-	c.CreatedAt = ct
+// ListByGroupCommand возвращает команды, созданные групповой командой.
+func (r *CommandRepo) ListByGroupCommand(ctx context.Context, groupCommandID string) ([]Command, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+commandColumns+`
+		FROM commands
+		WHERE group_command_id = ?
+		ORDER BY created_at, id
+	`, groupCommandID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	if acked.Valid {
-		at, _ := time.Parse(time.RFC3339Nano, acked.String)
-		c.AckedAt = &at
+	var out []Command
+	for rows.Next() {
+		c, err := scanCommand(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
 	}
+	return out, rows.Err()
+}
 
-	return c, nil
+// CommandListQuery — фильтры истории команд устройства; нулевые поля не ограничивают.
+type CommandListQuery struct {
+	DeviceID string
+	Statuses []string
+	From     time.Time // created_at >= From
+	To       time.Time // created_at < To
+	Cursor   string    // NextCursor предыдущей страницы
+	Limit    int
+}
+
+type CommandPage struct {
+	Items      []Command
+	NextCursor string // пусто — больше страниц нет
 }
 
-func (r *CommandRepo) SetAck(ctx context.Context, id string, ok bool, errMsg string, at time.Time) error {
-	status := "acked"
+// commandsSort — единственный порядок истории команд (новые первыми).
+const commandsSort = "-createdAt"
+
+// ListByDevice возвращает команды устройства от новых к старым
+// (индекс idx_commands_device_created), keyset-пагинация по (created_at, id).
+func (r *CommandRepo) ListByDevice(ctx context.Context, q CommandListQuery) (CommandPage, error) {
+	where := []string{"device_id = ?"}
+	args := []any{q.DeviceID}
+	if len(q.Statuses) > 0 {
+		where = append(where, "status IN (?"+strings.Repeat(", ?", len(q.Statuses)-1)+")")
+		for _, st := range q.Statuses {
+			args = append(args, st)
+		}
+	}
+	if !q.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, formatTime(q.From))
+	}
+	if !q.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, formatTime(q.To))
+	}
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor, commandsSort)
+		if err != nil {
+			return CommandPage{}, err
+		}
+		where = append(where, "(created_at < ? OR (created_at = ? AND id < ?))")
+		args = append(args, c.Value, c.Value, c.ID)
+	}
+	// +1 запись, чтобы узнать, есть ли следующая страница
+	args = append(args, q.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+commandColumns+`
+		FROM commands
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return CommandPage{}, err
+	}
+	defer rows.Close()
+
+	var out []Command
+	for rows.Next() {
+		c, err := scanCommand(rows)
+		if err != nil {
+			return CommandPage{}, err
+		}
+		out = append(out, c)
+	}
+	if err := rows.Err(); err != nil {
+		return CommandPage{}, err
+	}
+
+	page := CommandPage{Items: out}
+	if len(out) > q.Limit {
+		page.Items = out[:q.Limit]
+		last := page.Items[q.Limit-1]
+		page.NextCursor = encodeCursor(pageCursor{Sort: commandsSort, Value: formatTime(last.CreatedAt), ID: last.ID})
+	}
+	return page, nil
+}
+
+// SetAck фиксирует ответ устройства. Обновляет только pending-команды:
+// false означает, что команда уже в финальном статусе (например, timeout).
+func (r *CommandRepo) SetAck(ctx context.Context, id string, ok bool, errMsg string, at time.Time) (bool, error) {
+	status := CommandAcked
 	if !ok {
-		status = "failed"
+		status = CommandFailed
 	}
-	_, err := r.db.ExecContext(ctx, `
+	res, err := r.db.ExecContext(ctx, `
 		UPDATE commands
 		SET status = ?, error = ?, acked_at = ?
-		WHERE id = ?
-	`, status, errMsg, at.UTC().Format(time.RFC3339Nano), id)
-	return err
+		WHERE id = ? AND status = 'pending'
+	`, status, errMsg, formatTime(at), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
 }
 
-func (r *CommandRepo) SetTimeout(ctx context.Context, id string) error {
-	_, err := r.db.ExecContext(ctx, `
+// SetTimeout переводит pending-команду в timeout; false — команда уже не pending.
+func (r *CommandRepo) SetTimeout(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
 		UPDATE commands
 		SET status = 'timeout'
 		WHERE id = ? AND status = 'pending'
 	`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SetFailed помечает команду как failed без ack (например, не удалось опубликовать).
+func (r *CommandRepo) SetFailed(ctx context.Context, id string, errMsg string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE commands
+		SET status = 'failed', error = ?
+		WHERE id = ? AND status = 'pending'
+	`, errMsg, id)
 	return err
 }
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCommand(row rowScanner) (Command, error) {
+	var c Command
+	var created string
+	var acked sql.NullString
+
+	if err := row.Scan(&c.ID, &c.DeviceID, &c.Action, &c.ParamsJSON, &c.Status, &c.Error, &created, &acked,
+		&c.Source, &c.CorrelationID, &c.GroupCommandID); err != nil {
+		return Command{}, err
+	}
+
+	c.CreatedAt = parseTime(created)
+	if acked.Valid {
+		at := parseTime(acked.String)
+		c.AckedAt = &at
+	}
+	return c, nil
+}
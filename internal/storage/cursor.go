@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// pageCursor — позиция keyset-пагинации: значение поля сортировки и id
+// последней отданной записи. Для клиента непрозрачна (base64 JSON).
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeCursor(c pageCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor разбирает курсор и проверяет, что он выдан для той же сортировки.
+func decodeCursor(s, sort string) (pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != sort || c.ID == "" {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
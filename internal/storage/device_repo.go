@@ -3,58 +3,224 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
-type DeviceRepo struct{ db *sql.DB }
+type DeviceRepo struct{ db queryer }
 
-func NewDeviceRepo(db *sql.DB) *DeviceRepo { return &DeviceRepo{db: db} }
+func NewDeviceRepo(db *sql.DB) *DeviceRepo { return &DeviceRepo{db: instrument(db)} }
+
+const deviceColumns = `id, name, type, mqtt_device_id, capabilities_json, home_id, room_id, created_at, online, last_seen_at`
 
 func (r *DeviceRepo) Create(ctx context.Context, d Device) error {
 	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO devices(id, name, type, mqtt_device_id, capabilities_json, created_at)
-		VALUES(?, ?, ?, ?, ?, ?)
-	`, d.ID, d.Name, d.Type, d.MQTTDeviceID, d.Capabilities, d.CreatedAt.UTC().Format(time.RFC3339Nano))
+		INSERT INTO devices(id, name, type, mqtt_device_id, capabilities_json, home_id, room_id, created_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+	`, d.ID, d.Name, d.Type, d.MQTTDeviceID, d.Capabilities, d.HomeID, nullString(d.RoomID), formatTime(d.CreatedAt))
+	return wrapConflict(err)
+}
+
+// Update сохраняет изменяемые поля устройства; false — устройства нет.
+func (r *DeviceRepo) Update(ctx context.Context, d Device) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE devices
+		SET name = ?, type = ?, mqtt_device_id = ?, capabilities_json = ?, home_id = ?, room_id = ?
+		WHERE id = ?
+	`, d.Name, d.Type, d.MQTTDeviceID, d.Capabilities, d.HomeID, nullString(d.RoomID), d.ID)
+	if err != nil {
+		return false, wrapConflict(err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SetCapabilities заменяет только capabilities (миграция устаревшего формата).
+func (r *DeviceRepo) SetCapabilities(ctx context.Context, id, capsJSON string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE devices SET capabilities_json = ? WHERE id = ?`, capsJSON, id)
 	return err
 }
 
 func (r *DeviceRepo) Get(ctx context.Context, id string) (Device, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+deviceColumns+` FROM devices WHERE id = ?`, id)
+	return scanDevice(row)
+}
+
+// GetByTopic ищет устройство по сегментам MQTT-топика home/{mqttHomeID}/device/{mqttDeviceID}.
+// Устройство из другого дома не находится (sql.ErrNoRows).
+func (r *DeviceRepo) GetByTopic(ctx context.Context, mqttHomeID, mqttDeviceID string) (Device, error) {
 	row := r.db.QueryRowContext(ctx, `
-		SELECT id, name, type, mqtt_device_id, capabilities_json, created_at
-		FROM devices WHERE id = ?
-	`, id)
+		SELECT `+deviceColumns+` FROM devices
+		WHERE mqtt_device_id = ?
+		  AND home_id = (SELECT id FROM homes WHERE mqtt_home_id = ?)
+	`, mqttDeviceID, mqttHomeID)
+	return scanDevice(row)
+}
 
-	var d Device
-	var created string
-	if err := row.Scan(&d.ID, &d.Name, &d.Type, &d.MQTTDeviceID, &d.Capabilities, &created); err != nil {
-		return Device{}, err
+// Сортировки списка устройств; "-" — по убыванию.
+const (
+	DeviceSortCreatedDesc = "-createdAt"
+	DeviceSortCreatedAsc  = "createdAt"
+	DeviceSortNameAsc     = "name"
+	DeviceSortNameDesc    = "-name"
+)
+
+var ErrInvalidSort = errors.New("invalid sort")
+
+// DeviceListQuery — фильтры и страница для DeviceRepo.List; пустые поля не фильтруют.
+type DeviceListQuery struct {
+	HomeID     string
+	RoomID     string
+	Type       string
+	Capability string
+	NamePrefix string
+	Sort       string // по умолчанию DeviceSortCreatedDesc
+	Cursor     string // NextCursor предыдущей страницы
+	Limit      int
+}
+
+type DevicePage struct {
+	Items      []Device
+	NextCursor string // пусто — больше страниц нет
+}
+
+// List возвращает страницу устройств. Пагинация keyset по (поле сортировки, id):
+// вставки между запросами не сдвигают и не дублируют записи.
+func (r *DeviceRepo) List(ctx context.Context, q DeviceListQuery) (DevicePage, error) {
+	if q.Sort == "" {
+		q.Sort = DeviceSortCreatedDesc
 	}
-	t, _ := time.Parse(time.RFC3339Nano, created)
-	d.CreatedAt = t
-	return d, nil
+
+	var col, dir string
+	switch q.Sort {
+	case DeviceSortCreatedDesc:
+		col, dir = "created_at", "DESC"
+	case DeviceSortCreatedAsc:
+		col, dir = "created_at", "ASC"
+	case DeviceSortNameAsc:
+		col, dir = "name", "ASC"
+	case DeviceSortNameDesc:
+		col, dir = "name", "DESC"
+	default:
+		return DevicePage{}, ErrInvalidSort
+	}
+
+	var where []string
+	var args []any
+	if q.HomeID != "" {
+		where = append(where, "home_id = ?")
+		args = append(args, q.HomeID)
+	}
+	if q.RoomID != "" {
+		where = append(where, "room_id = ?")
+		args = append(args, q.RoomID)
+	}
+	if q.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, q.Type)
+	}
+	if q.Capability != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM json_each(capabilities_json) WHERE value = ?)")
+		args = append(args, q.Capability)
+	}
+	if q.NamePrefix != "" {
+		where = append(where, `name LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(q.NamePrefix)+"%")
+	}
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor, q.Sort)
+		if err != nil {
+			return DevicePage{}, err
+		}
+		cmp := "<"
+		if dir == "ASC" {
+			cmp = ">"
+		}
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", col, cmp))
+		args = append(args, c.Value, c.Value, c.ID)
+	}
+
+	query := `SELECT ` + deviceColumns + ` FROM devices`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s LIMIT ?", col, dir)
+	// +1 запись, чтобы узнать, есть ли следующая страница
+	args = append(args, q.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return DevicePage{}, err
+	}
+	defer rows.Close()
+
+	var out []Device
+	for rows.Next() {
+		d, err := scanDevice(rows)
+		if err != nil {
+			return DevicePage{}, err
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return DevicePage{}, err
+	}
+
+	page := DevicePage{Items: out}
+	if len(out) > q.Limit {
+		page.Items = out[:q.Limit]
+		last := page.Items[q.Limit-1]
+		value := formatTime(last.CreatedAt)
+		if col == "name" {
+			value = last.Name
+		}
+		page.NextCursor = encodeCursor(pageCursor{Sort: q.Sort, Value: value, ID: last.ID})
+	}
+	return page, nil
 }
 
-func (r *DeviceRepo) GetByMQTTDeviceID(ctx context.Context, mqttID string) (Device, error) {
-	row := r.db.QueryRowContext(ctx, `
-		SELECT id, name, type, mqtt_device_id, capabilities_json, created_at
-		FROM devices WHERE mqtt_device_id = ?
-	`, mqttID)
+// escapeLike экранирует спецсимволы LIKE (\, %, _).
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
 
-	var d Device
-	var created string
-	if err := row.Scan(&d.ID, &d.Name, &d.Type, &d.MQTTDeviceID, &d.Capabilities, &created); err != nil {
-		return Device{}, err
+// MarkSeen обновляет last_seen_at и помечает устройство online.
+// cameOnline — устройство до этого было offline.
+func (r *DeviceRepo) MarkSeen(ctx context.Context, id string, at time.Time) (cameOnline bool, err error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE devices SET online = 1, last_seen_at = ? WHERE id = ? AND online = 0
+	`, formatTime(at), id)
+	if err != nil {
+		return false, err
 	}
-	t, _ := time.Parse(time.RFC3339Nano, created)
-	d.CreatedAt = t
-	return d, nil
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return n > 0, err
+	}
+	_, err = r.db.ExecContext(ctx, `UPDATE devices SET last_seen_at = ? WHERE id = ?`, formatTime(at), id)
+	return false, err
+}
+
+// SetOffline помечает устройство offline; false — оно уже offline (или его нет).
+func (r *DeviceRepo) SetOffline(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `UPDATE devices SET online = 0 WHERE id = ? AND online = 1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
 }
 
-func (r *DeviceRepo) List(ctx context.Context) ([]Device, error) {
+// SetOfflineSilentSince помечает offline все online-устройства, от которых
+// ничего не было с before, и возвращает их. Одним UPDATE, чтобы не
+// перетереть MarkSeen, пришедший между выборкой и обновлением.
+func (r *DeviceRepo) SetOfflineSilentSince(ctx context.Context, before time.Time) ([]Device, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, name, type, mqtt_device_id, capabilities_json, created_at
-		FROM devices ORDER BY created_at DESC
-	`)
+		UPDATE devices SET online = 0
+		WHERE online = 1 AND last_seen_at < ?
+		RETURNING `+deviceColumns+`
+	`, formatTime(before))
 	if err != nil {
 		return nil, err
 	}
@@ -62,19 +228,43 @@ func (r *DeviceRepo) List(ctx context.Context) ([]Device, error) {
 
 	var out []Device
 	for rows.Next() {
-		var d Device
-		var created string
-		if err := rows.Scan(&d.ID, &d.Name, &d.Type, &d.MQTTDeviceID, &d.Capabilities, &created); err != nil {
+		d, err := scanDevice(rows)
+		if err != nil {
 			return nil, err
 		}
-		t, _ := time.Parse(time.RFC3339Nano, created)
-		d.CreatedAt = t
 		out = append(out, d)
 	}
 	return out, rows.Err()
 }
 
-func (r *DeviceRepo) Delete(ctx context.Context, id string) error {
-	_, err := r.db.ExecContext(ctx, `DELETE FROM devices WHERE id = ?`, id)
-	return err
+// Delete возвращает false, если устройства не было.
+func (r *DeviceRepo) Delete(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM devices WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanDevice(row rowScanner) (Device, error) {
+	var d Device
+	var room, seen sql.NullString
+	var created string
+	if err := row.Scan(&d.ID, &d.Name, &d.Type, &d.MQTTDeviceID, &d.Capabilities, &d.HomeID, &room, &created,
+		&d.Online, &seen); err != nil {
+		return Device{}, err
+	}
+	d.RoomID = room.String
+	d.CreatedAt = parseTime(created)
+	if seen.Valid {
+		at := parseTime(seen.String)
+		d.LastSeenAt = &at
+	}
+	return d, nil
+}
+
+// nullString хранит пустую строку как NULL (для ссылок внешних ключей).
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// ErrConflict — нарушение уникальности (например, mqtt_device_id).
+var ErrConflict = errors.New("unique constraint violation")
+
+// wrapConflict заменяет ошибку UNIQUE-ограничения SQLite на ErrConflict.
+func wrapConflict(err error) error {
+	var se *sqlite.Error
+	if errors.As(err, &se) && (se.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || se.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) {
+		return errors.Join(ErrConflict, err)
+	}
+	return err
+}
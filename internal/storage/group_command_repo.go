@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+)
+
+type GroupCommandRepo struct{ db queryer }
+
+func NewGroupCommandRepo(db *sql.DB) *GroupCommandRepo { return &GroupCommandRepo{db: instrument(db)} }
+
+const groupCommandColumns = `id, group_id, action, params_json, rejected_json, created_at`
+
+func (r *GroupCommandRepo) Create(ctx context.Context, gc GroupCommand) error {
+	if gc.RejectedJSON == "" {
+		gc.RejectedJSON = "[]"
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO group_commands(id, group_id, action, params_json, rejected_json, created_at)
+		VALUES(?, ?, ?, ?, ?, ?)
+	`, gc.ID, gc.GroupID, gc.Action, gc.ParamsJSON, gc.RejectedJSON, formatTime(gc.CreatedAt))
+	return err
+}
+
+func (r *GroupCommandRepo) Get(ctx context.Context, id string) (GroupCommand, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+groupCommandColumns+` FROM group_commands WHERE id = ?`, id)
+
+	var gc GroupCommand
+	var created string
+	if err := row.Scan(&gc.ID, &gc.GroupID, &gc.Action, &gc.ParamsJSON, &gc.RejectedJSON, &created); err != nil {
+		return GroupCommand{}, err
+	}
+	gc.CreatedAt = parseTime(created)
+	return gc, nil
+}
+
+// SetRejected сохраняет участников, которым команда не была создана.
+func (r *GroupCommandRepo) SetRejected(ctx context.Context, id, rejectedJSON string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE group_commands SET rejected_json = ? WHERE id = ?`, rejectedJSON, id)
+	return err
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+type GroupRepo struct {
+	db   queryer
+	conn *sql.DB // для транзакций (группа + участники)
+}
+
+func NewGroupRepo(db *sql.DB) *GroupRepo { return &GroupRepo{db: instrument(db), conn: db} }
+
+const groupColumns = `id, name, created_at, updated_at`
+
+// Create сохраняет группу с участниками. Занятое имя — ErrConflict.
+func (r *GroupRepo) Create(ctx context.Context, g Group) error {
+	return withTx(ctx, r.conn, func(q queryer) error {
+		_, err := q.ExecContext(ctx, `
+			INSERT INTO device_groups(id, name, created_at, updated_at)
+			VALUES(?, ?, ?, ?)
+		`, g.ID, g.Name, formatTime(g.CreatedAt), formatTime(g.UpdatedAt))
+		if err != nil {
+			return wrapConflict(err)
+		}
+		return insertMembers(ctx, q, g.ID, g.DeviceIDs)
+	})
+}
+
+func (r *GroupRepo) Get(ctx context.Context, id string) (Group, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+groupColumns+` FROM device_groups WHERE id = ?`, id)
+	g, err := scanGroup(row)
+	if err != nil {
+		return Group{}, err
+	}
+	members, err := r.members(ctx, `WHERE group_id = ?`, id)
+	if err != nil {
+		return Group{}, err
+	}
+	g.DeviceIDs = members[g.ID]
+	return g, nil
+}
+
+func (r *GroupRepo) List(ctx context.Context) ([]Group, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+groupColumns+` FROM device_groups ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Group
+	for rows.Next() {
+		g, err := scanGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	members, err := r.members(ctx, ``)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].DeviceIDs = members[out[i].ID]
+	}
+	return out, nil
+}
+
+// Update сохраняет имя и заменяет состав группы; false — группы нет.
+func (r *GroupRepo) Update(ctx context.Context, g Group) (bool, error) {
+	var updated bool
+	err := withTx(ctx, r.conn, func(q queryer) error {
+		res, err := q.ExecContext(ctx, `
+			UPDATE device_groups SET name = ?, updated_at = ? WHERE id = ?
+		`, g.Name, formatTime(g.UpdatedAt), g.ID)
+		if err != nil {
+			return wrapConflict(err)
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		updated = true
+
+		if _, err := q.ExecContext(ctx, `DELETE FROM device_group_members WHERE group_id = ?`, g.ID); err != nil {
+			return err
+		}
+		return insertMembers(ctx, q, g.ID, g.DeviceIDs)
+	})
+	return updated, err
+}
+
+func (r *GroupRepo) Delete(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM device_groups WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// members возвращает участников групп (group_id -> device_id), where — фильтр.
+func (r *GroupRepo) members(ctx context.Context, where string, args ...any) (map[string][]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT group_id, device_id FROM device_group_members `+where+`
+		ORDER BY group_id, device_id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string][]string{}
+	for rows.Next() {
+		var gid, did string
+		if err := rows.Scan(&gid, &did); err != nil {
+			return nil, err
+		}
+		out[gid] = append(out[gid], did)
+	}
+	return out, rows.Err()
+}
+
+func insertMembers(ctx context.Context, q queryer, groupID string, deviceIDs []string) error {
+	if len(deviceIDs) == 0 {
+		return nil
+	}
+	args := make([]any, 0, 2*len(deviceIDs))
+	for _, id := range deviceIDs {
+		args = append(args, groupID, id)
+	}
+	_, err := q.ExecContext(ctx, `
+		INSERT OR IGNORE INTO device_group_members(group_id, device_id)
+		VALUES `+strings.TrimSuffix(strings.Repeat("(?, ?), ", len(deviceIDs)), ", "),
+		args...)
+	return err
+}
+
+func scanGroup(row rowScanner) (Group, error) {
+	var g Group
+	var created, updated string
+	if err := row.Scan(&g.ID, &g.Name, &created, &updated); err != nil {
+		return Group{}, err
+	}
+	g.CreatedAt = parseTime(created)
+	g.UpdatedAt = parseTime(updated)
+	return g, nil
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// HistoryRepo — журнал телеметрии (append-only), для графиков по полям состояния.
+type HistoryRepo struct{ db queryer }
+
+func NewHistoryRepo(db *sql.DB) *HistoryRepo { return &HistoryRepo{db: instrument(db)} }
+
+type HistoryPoint struct {
+	At    time.Time
+	Value float64
+}
+
+// HistoryBucket — агрегат значений поля за интервал [Start, Start+step).
+type HistoryBucket struct {
+	Start time.Time
+	Min   float64
+	Max   float64
+	Avg   float64
+	Count int
+}
+
+func (r *HistoryRepo) Append(ctx context.Context, s DeviceState) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO telemetry_history(device_id, at, state_json)
+		VALUES(?, ?, ?)
+	`, s.DeviceID, formatTime(s.UpdatedAt), s.StateJSON)
+	return err
+}
+
+// Points возвращает числовые значения поля в [from, to) по возрастанию времени.
+// field — путь в state через точку ("temperature", "sensor.temp"); записи,
+// где поле отсутствует или не число, пропускаются.
+func (r *HistoryRepo) Points(ctx context.Context, deviceID, field string, from, to time.Time, limit int) ([]HistoryPoint, error) {
+	path := "$." + field
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT at, json_extract(state_json, ?)
+		FROM telemetry_history
+		WHERE device_id = ? AND at >= ? AND at < ?
+		  AND json_type(state_json, ?) IN ('integer', 'real')
+		ORDER BY at
+		LIMIT ?
+	`, path, deviceID, formatTime(from), formatTime(to), path, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []HistoryPoint
+	for rows.Next() {
+		var p HistoryPoint
+		var at string
+		if err := rows.Scan(&at, &p.Value); err != nil {
+			return nil, err
+		}
+		p.At = parseTime(at)
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// Buckets агрегирует числовые значения поля в [from, to) по интервалам step,
+// выровненным по Unix-эпохе (step=15m — :00, :15, ...), поэтому первый интервал
+// может начинаться раньше from. Пустые интервалы не возвращаются.
+func (r *HistoryRepo) Buckets(ctx context.Context, deviceID, field string, from, to time.Time, step time.Duration) ([]HistoryBucket, error) {
+	path := "$." + field
+	stepSec := int64(step / time.Second)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT CAST(strftime('%s', at) AS INTEGER) / ? AS bucket,
+		       MIN(v), MAX(v), AVG(v), COUNT(*)
+		FROM (
+		  SELECT at, json_extract(state_json, ?) AS v
+		  FROM telemetry_history
+		  WHERE device_id = ? AND at >= ? AND at < ?
+		    AND json_type(state_json, ?) IN ('integer', 'real')
+		)
+		GROUP BY bucket
+		ORDER BY bucket
+	`, stepSec, path, deviceID, formatTime(from), formatTime(to), path)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []HistoryBucket
+	for rows.Next() {
+		var b HistoryBucket
+		var n int64
+		if err := rows.Scan(&n, &b.Min, &b.Max, &b.Avg, &b.Count); err != nil {
+			return nil, err
+		}
+		b.Start = time.Unix(n*stepSec, 0).UTC()
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// DeleteBefore удаляет записи старше before и возвращает их количество.
+func (r *HistoryRepo) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM telemetry_history WHERE at < ?`, formatTime(before))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type HomeRepo struct{ db queryer }
+
+func NewHomeRepo(db *sql.DB) *HomeRepo { return &HomeRepo{db: instrument(db)} }
+
+const homeColumns = `id, name, mqtt_home_id, created_at`
+
+func (r *HomeRepo) Create(ctx context.Context, h Home) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO homes(id, name, mqtt_home_id, created_at)
+		VALUES(?, ?, ?, ?)
+	`, h.ID, h.Name, h.MQTTHomeID, formatTime(h.CreatedAt))
+	return wrapConflict(err)
+}
+
+// EnsureDefault создаёт дом DefaultHomeID, если его ещё нет. mqttHomeID
+// используется только при создании; дальше дом меняется через API.
+func (r *HomeRepo) EnsureDefault(ctx context.Context, mqttHomeID string, now time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO homes(id, name, mqtt_home_id, created_at)
+		VALUES(?, 'Default', ?, ?)
+		ON CONFLICT(id) DO NOTHING
+	`, DefaultHomeID, mqttHomeID, formatTime(now))
+	return wrapConflict(err)
+}
+
+func (r *HomeRepo) Get(ctx context.Context, id string) (Home, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+homeColumns+` FROM homes WHERE id = ?`, id)
+	return scanHome(row)
+}
+
+func (r *HomeRepo) List(ctx context.Context) ([]Home, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+homeColumns+` FROM homes ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Home
+	for rows.Next() {
+		h, err := scanHome(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+// Update сохраняет name и mqtt_home_id; false — дома нет.
+func (r *HomeRepo) Update(ctx context.Context, h Home) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE homes SET name = ?, mqtt_home_id = ? WHERE id = ?
+	`, h.Name, h.MQTTHomeID, h.ID)
+	if err != nil {
+		return false, wrapConflict(err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteEmpty удаляет дом вместе с комнатами, только если в нём нет устройств.
+// false — дома нет или он не пуст.
+func (r *HomeRepo) DeleteEmpty(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM homes
+		WHERE id = ? AND NOT EXISTS (SELECT 1 FROM devices WHERE home_id = ?)
+	`, id, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanHome(row rowScanner) (Home, error) {
+	var h Home
+	var created string
+	if err := row.Scan(&h.ID, &h.Name, &h.MQTTHomeID, &created); err != nil {
+		return Home{}, err
+	}
+	h.CreatedAt = parseTime(created)
+	return h, nil
+}
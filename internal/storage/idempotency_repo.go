@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type IdempotencyRepo struct{ db queryer }
+
+func NewIdempotencyRepo(db *sql.DB) *IdempotencyRepo { return &IdempotencyRepo{db: instrument(db)} }
+
+// Reserve занимает ключ под выполняющийся запрос. Истёкшая запись, как и
+// незавершённая старше staleBefore (процесс упал посреди запроса), перезаписывается.
+// Если ключ занят, возвращается существующая запись и false.
+func (r *IdempotencyRepo) Reserve(ctx context.Context, rec IdempotencyRecord, staleBefore time.Time) (IdempotencyRecord, bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO idempotency_keys(principal, idem_key, request_hash, status_code, headers_json, body, created_at, expires_at)
+		VALUES(?, ?, ?, NULL, '{}', NULL, ?, ?)
+		ON CONFLICT(principal, idem_key) DO UPDATE SET
+			request_hash = excluded.request_hash,
+			status_code = NULL,
+			headers_json = '{}',
+			body = NULL,
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at
+		WHERE idempotency_keys.expires_at <= excluded.created_at
+		   OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at <= ?)
+	`, rec.Principal, rec.Key, rec.RequestHash, formatTime(rec.CreatedAt), formatTime(rec.ExpiresAt), formatTime(staleBefore))
+	if err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	if n > 0 {
+		return rec, true, nil
+	}
+
+	existing, err := r.Get(ctx, rec.Principal, rec.Key)
+	return existing, false, err
+}
+
+func (r *IdempotencyRepo) Get(ctx context.Context, principal, key string) (IdempotencyRecord, error) {
+	var rec IdempotencyRecord
+	var status sql.NullInt64
+	var createdAt, expiresAt string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT principal, idem_key, request_hash, status_code, headers_json, body, created_at, expires_at
+		FROM idempotency_keys WHERE principal = ? AND idem_key = ?
+	`, principal, key).Scan(&rec.Principal, &rec.Key, &rec.RequestHash, &status, &rec.HeadersJSON, &rec.Body, &createdAt, &expiresAt)
+	if err != nil {
+		return IdempotencyRecord{}, err
+	}
+	rec.StatusCode = int(status.Int64)
+	rec.CreatedAt = parseTime(createdAt)
+	rec.ExpiresAt = parseTime(expiresAt)
+	return rec, nil
+}
+
+// Complete сохраняет ответ выполненного запроса.
+func (r *IdempotencyRepo) Complete(ctx context.Context, rec IdempotencyRecord) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE idempotency_keys SET status_code = ?, headers_json = ?, body = ?
+		WHERE principal = ? AND idem_key = ? AND request_hash = ?
+	`, rec.StatusCode, rec.HeadersJSON, rec.Body, rec.Principal, rec.Key, rec.RequestHash)
+	return err
+}
+
+// Release освобождает незавершённый ключ: ответ не сохраняется, повтор выполнится заново.
+func (r *IdempotencyRepo) Release(ctx context.Context, principal, key string) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys WHERE principal = ? AND idem_key = ? AND status_code IS NULL
+	`, principal, key)
+	return err
+}
+
+// DeleteExpired удаляет записи с истёкшим сроком.
+func (r *IdempotencyRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= ?`, formatTime(now))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QueryObserver получает длительность каждого запроса репозиториев.
+// op — "<verb>_<table>", например "select_devices".
+type QueryObserver func(op string, d time.Duration, err error)
+
+var observer atomic.Pointer[QueryObserver]
+
+// SetQueryObserver подключает наблюдателя (метрики); nil отключает.
+func SetQueryObserver(fn QueryObserver) {
+	if fn == nil {
+		observer.Store(nil)
+		return
+	}
+	observer.Store(&fn)
+}
+
+// queryer — то, чем пользуются репозитории; реализуется instrumentedDB.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// instrumentedDB оборачивает *sql.DB или *sql.Tx.
+type instrumentedDB struct{ db queryer }
+
+func instrument(db *sql.DB) queryer { return instrumentedDB{db: db} }
+
+// withTx выполняет fn в транзакции; запросы внутри тоже наблюдаются.
+func withTx(ctx context.Context, db *sql.DB, fn func(q queryer) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(instrumentedDB{db: tx}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (i instrumentedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	res, err := i.db.ExecContext(ctx, query, args...)
+	observe(query, start, err)
+	return res, err
+}
+
+func (i instrumentedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := i.db.QueryContext(ctx, query, args...)
+	observe(query, start, err)
+	return rows, err
+}
+
+func (i instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := i.db.QueryRowContext(ctx, query, args...)
+	err := row.Err()
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	observe(query, start, err)
+	return row
+}
+
+func observe(query string, start time.Time, err error) {
+	fn := observer.Load()
+	if fn == nil {
+		return
+	}
+	(*fn)(queryOp(query), time.Since(start), err)
+}
+
+var opCache sync.Map // query -> op
+
+// queryOp выводит имя операции из текста запроса: глагол + первая таблица.
+func queryOp(query string) string {
+	if op, ok := opCache.Load(query); ok {
+		return op.(string)
+	}
+
+	fields := strings.Fields(strings.ToLower(query))
+	op := "unknown"
+	if len(fields) > 0 {
+		verb := fields[0]
+		table := ""
+		for i, f := range fields[:len(fields)-1] {
+			if f == "from" || f == "into" || (f == "update" && i == 0) {
+				table = fields[i+1]
+				if j := strings.IndexAny(table, "(,"); j >= 0 {
+					table = table[:j]
+				}
+				break
+			}
+		}
+		op = verb
+		if table != "" {
+			op += "_" + table
+		}
+	}
+
+	opCache.Store(query, op)
+	return op
+}
@@ -31,6 +31,20 @@ func Migrate(ctx context.Context, db *sql.DB) error {
 	}
 	sort.Strings(files)
 
+	// Миграции, пересоздающие таблицу (CREATE new + DROP old + RENAME), не должны
+	// каскадно чистить зависимые таблицы, поэтому FK на время миграций выключены.
+	// PRAGMA foreign_keys не действует внутри транзакции и задаётся на соединение —
+	// все миграции идут через одно соединение, целостность проверяется перед коммитом.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), `PRAGMA foreign_keys = ON`)
+
 	for _, f := range files {
 		v, err := parseVersion(f) // migrations/0001_init.sql -> 1
 		if err != nil {
@@ -45,7 +59,7 @@ func Migrate(ctx context.Context, db *sql.DB) error {
 			return err
 		}
 
-		tx, err := db.BeginTx(ctx, nil)
+		tx, err := conn.BeginTx(ctx, nil)
 		if err != nil {
 			return err
 		}
@@ -55,6 +69,11 @@ func Migrate(ctx context.Context, db *sql.DB) error {
 			return fmt.Errorf("migration %s failed: %w", f, err)
 		}
 
+		if err := checkForeignKeys(ctx, tx); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %s failed: %w", f, err)
+		}
+
 		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations(version) VALUES (?)`, v); err != nil {
 			_ = tx.Rollback()
 			return err
@@ -79,6 +98,25 @@ func currentVersion(ctx context.Context, db *sql.DB) (int, error) {
 	return v, nil
 }
 
+// checkForeignKeys — замена проверок FK, выключенных на время миграций.
+func checkForeignKeys(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		var table, parent string
+		var rowID sql.NullInt64
+		var fkID int
+		if err := rows.Scan(&table, &rowID, &parent, &fkID); err != nil {
+			return err
+		}
+		return fmt.Errorf("foreign key violation: %s row %d references missing %s", table, rowID.Int64, parent)
+	}
+	return rows.Err()
+}
+
 func parseVersion(path string) (int, error) {
 	// "migrations/0001_init.sql" -> "0001"
 	base := path[strings.LastIndex(path, "/")+1:]
@@ -91,3 +129,36 @@ func parseVersion(path string) (int, error) {
 	}
 	return n, nil
 }
+
+// LatestVersion — номер последней встроенной миграции.
+func LatestVersion() (int, error) {
+	files, err := fs.Glob(migrationsFS, "migrations/*.sql")
+	if err != nil {
+		return 0, err
+	}
+	latest := 0
+	for _, f := range files {
+		v, err := parseVersion(f)
+		if err != nil {
+			return 0, err
+		}
+		latest = max(latest, v)
+	}
+	return latest, nil
+}
+
+// CheckVersion сверяет версию схемы в БД с последней встроенной миграцией.
+func CheckVersion(ctx context.Context, db *sql.DB) error {
+	latest, err := LatestVersion()
+	if err != nil {
+		return err
+	}
+	cur, err := currentVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if cur != latest {
+		return fmt.Errorf("schema version %d, expected %d", cur, latest)
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS rules (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  enabled INTEGER NOT NULL DEFAULT 1,
+  trigger_json TEXT NOT NULL,     -- {"type":"state_changed","deviceId":...} | {"type":"timer","every":"5m"}
+  conditions_json TEXT NOT NULL,  -- [{"deviceId":...,"field":"motion","op":"eq","value":true}]
+  actions_json TEXT NOT NULL,     -- [{"deviceId":...,"action":"turn_on","params":{},"delay":"2m"}]
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+-- откуда пришла команда (api|ws|rule:<id>) и цепочка, которой она принадлежит
+ALTER TABLE commands ADD COLUMN source TEXT NOT NULL DEFAULT 'api';
+ALTER TABLE commands ADD COLUMN correlation_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL UNIQUE,
+  key_hash TEXT NOT NULL UNIQUE,  -- sha256(key) hex, сам ключ не храним
+  scopes_json TEXT NOT NULL,      -- ["devices:read","commands:send","admin"]
+  created_at TEXT NOT NULL,
+  revoked_at TEXT
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  at TEXT NOT NULL,
+  actor TEXT NOT NULL,        -- имя API-ключа
+  request_id TEXT NOT NULL,
+  method TEXT NOT NULL,
+  route TEXT NOT NULL,        -- шаблон маршрута: "POST /api/v1/devices/{id}/commands"
+  resource TEXT NOT NULL,     -- "device:<id>", "command:<id>", ...
+  status INTEGER NOT NULL,
+  outcome TEXT NOT NULL       -- success|failure
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_at ON audit_log(at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_at ON audit_log(actor, at);
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS homes (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  mqtt_home_id TEXT NOT NULL UNIQUE,  -- сегмент топика home/{mqtt_home_id}/device/...
+  created_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS rooms (
+  id TEXT PRIMARY KEY,
+  home_id TEXT NOT NULL,
+  name TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  UNIQUE(home_id, name),
+  FOREIGN KEY(home_id) REFERENCES homes(id) ON DELETE CASCADE
+);
+
+-- дом по умолчанию ('1') создаёт EnsureDefaultHome при старте;
+-- SQLite не позволяет ADD COLUMN с REFERENCES и непустым DEFAULT,
+-- поэтому home_id проверяется в приложении
+ALTER TABLE devices ADD COLUMN home_id TEXT NOT NULL DEFAULT '1';
+ALTER TABLE devices ADD COLUMN room_id TEXT REFERENCES rooms(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_devices_home ON devices(home_id);
+CREATE INDEX IF NOT EXISTS idx_devices_room ON devices(room_id);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS telemetry_history (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  device_id TEXT NOT NULL,
+  at TEXT NOT NULL,
+  state_json TEXT NOT NULL,     -- состояние из телеметрии, как в device_state
+  FOREIGN KEY(device_id) REFERENCES devices(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_telemetry_history_device_at ON telemetry_history(device_id, at);
+-- для удаления по retention
+CREATE INDEX IF NOT EXISTS idx_telemetry_history_at ON telemetry_history(at);
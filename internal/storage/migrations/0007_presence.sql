@@ -0,0 +1,5 @@
+-- присутствие: последнее сообщение от устройства (telemetry/ack/status)
+ALTER TABLE devices ADD COLUMN online INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE devices ADD COLUMN last_seen_at TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_devices_online_seen ON devices(online, last_seen_at);
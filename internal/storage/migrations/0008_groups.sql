@@ -0,0 +1,33 @@
+-- "groups" — ключевое слово SQLite (оконные функции), поэтому device_groups
+CREATE TABLE IF NOT EXISTS device_groups (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL UNIQUE,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS device_group_members (
+  group_id TEXT NOT NULL,
+  device_id TEXT NOT NULL,
+  PRIMARY KEY(group_id, device_id),
+  FOREIGN KEY(group_id) REFERENCES device_groups(id) ON DELETE CASCADE,
+  FOREIGN KEY(device_id) REFERENCES devices(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_group_members_device ON device_group_members(device_id);
+
+-- групповая команда: по одной команде на участника (commands.group_command_id)
+CREATE TABLE IF NOT EXISTS group_commands (
+  id TEXT PRIMARY KEY,
+  group_id TEXT NOT NULL,
+  action TEXT NOT NULL,
+  params_json TEXT NOT NULL,
+  rejected_json TEXT NOT NULL DEFAULT '[]',  -- [{"deviceId":...,"error":...}] — кому команда не создана
+  created_at TEXT NOT NULL,
+  FOREIGN KEY(group_id) REFERENCES device_groups(id) ON DELETE CASCADE
+);
+
+ALTER TABLE commands ADD COLUMN group_command_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_commands_group_command
+ON commands(group_command_id) WHERE group_command_id != '';
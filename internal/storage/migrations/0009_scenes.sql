@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS scenes (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL UNIQUE,
+  steps_json TEXT NOT NULL,  -- [{"deviceId":...,"action":"turn_on","params":{},"delay":"2s"}]
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+-- запуск сцены: шаги с результатом (commandId, status, error)
+CREATE TABLE IF NOT EXISTS scene_runs (
+  id TEXT PRIMARY KEY,
+  scene_id TEXT NOT NULL,
+  source TEXT NOT NULL,
+  status TEXT NOT NULL,      -- running|completed|cancelled|interrupted
+  steps_json TEXT NOT NULL,
+  started_at TEXT NOT NULL,
+  finished_at TEXT,
+  FOREIGN KEY(scene_id) REFERENCES scenes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_scene_runs_scene_started ON scene_runs(scene_id, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_scene_runs_running ON scene_runs(status) WHERE status = 'running';
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS schedules (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  enabled INTEGER NOT NULL DEFAULT 1,
+  timezone TEXT NOT NULL DEFAULT '',  -- IANA; пусто — TIMEZONE из конфига
+  trigger_json TEXT NOT NULL,         -- {"type":"cron","cron":"0 7 * * *"} | {"type":"sun","event":"sunset","offset":"-30m"}
+  target_json TEXT NOT NULL,          -- {"deviceId":...,"action":...,"params":{}} | {"sceneId":...}
+  -- следующее срабатывание; планировщик забирает его CAS-ом по этому значению,
+  -- поэтому после рестарта и при нескольких процессах срабатывание не повторится
+  next_run_at TEXT,
+  last_run_at TEXT,
+  last_status TEXT NOT NULL DEFAULT '',  -- ok|error|missed
+  last_error TEXT NOT NULL DEFAULT '',
+  last_command_id TEXT NOT NULL DEFAULT '',
+  last_scene_run_id TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedules_next_run ON schedules(next_run_at) WHERE enabled = 1;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+  id TEXT PRIMARY KEY,
+  url TEXT NOT NULL,
+  event_types_json TEXT NOT NULL,  -- ["device.state_changed","command.*"]; [] — все события
+  secret TEXT NOT NULL,            -- ключ HMAC-SHA256; нужен в открытом виде для подписи
+  enabled INTEGER NOT NULL DEFAULT 1,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+-- доставка события подписчику; dead — исчерпаны попытки (dead-letter)
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id TEXT PRIMARY KEY,
+  webhook_id TEXT NOT NULL,
+  event_type TEXT NOT NULL,
+  payload_json TEXT NOT NULL,
+  status TEXT NOT NULL,            -- pending|delivered|dead
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TEXT,            -- для pending; его же переносит CAS при захвате попытки
+  last_status_code INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL,
+  FOREIGN KEY(webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due
+ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_created
+ON webhook_deliveries(webhook_id, created_at DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status_created
+ON webhook_deliveries(status, created_at DESC, id DESC);
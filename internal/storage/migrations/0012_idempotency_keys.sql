@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  principal TEXT NOT NULL,       -- id API-ключа ("bootstrap" для ключа из env)
+  idem_key TEXT NOT NULL,        -- значение заголовка Idempotency-Key
+  request_hash TEXT NOT NULL,    -- sha256 метода, пути и тела
+  status_code INTEGER,           -- NULL — запрос ещё выполняется
+  headers_json TEXT NOT NULL DEFAULT '{}',
+  body BLOB,
+  created_at TEXT NOT NULL,
+  expires_at TEXT NOT NULL,
+  PRIMARY KEY (principal, idem_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at);
@@ -0,0 +1,26 @@
+-- mqtt_device_id уникален в пределах дома, а не глобально: топики
+-- home/{mqtt_home_id}/device/{mqtt_device_id}/... у разных домов не пересекаются.
+-- SQLite не умеет снимать UNIQUE с колонки, поэтому таблица пересоздаётся.
+CREATE TABLE devices_new (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  type TEXT NOT NULL,
+  mqtt_device_id TEXT NOT NULL,
+  capabilities_json TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  home_id TEXT NOT NULL DEFAULT '1',
+  room_id TEXT REFERENCES rooms(id) ON DELETE SET NULL,
+  online INTEGER NOT NULL DEFAULT 0,
+  last_seen_at TEXT,
+  UNIQUE(home_id, mqtt_device_id)
+);
+
+INSERT INTO devices_new(id, name, type, mqtt_device_id, capabilities_json, created_at, home_id, room_id, online, last_seen_at)
+SELECT id, name, type, mqtt_device_id, capabilities_json, created_at, home_id, room_id, online, last_seen_at FROM devices;
+
+DROP TABLE devices;
+ALTER TABLE devices_new RENAME TO devices;
+
+-- idx_devices_home не нужен: его покрывает UNIQUE(home_id, mqtt_device_id)
+CREATE INDEX IF NOT EXISTS idx_devices_room ON devices(room_id);
+CREATE INDEX IF NOT EXISTS idx_devices_online_seen ON devices(online, last_seen_at);
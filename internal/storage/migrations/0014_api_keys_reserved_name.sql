@@ -0,0 +1,3 @@
+-- Имя "bootstrap" занято ключом из env API_KEY: аудит и лимиты ведутся по имени.
+-- Ключи, созданные с этим именем раньше, переименовываются.
+UPDATE api_keys SET name = name || '-' || substr(id, 1, 8) WHERE lower(name) = 'bootstrap';
@@ -0,0 +1,19 @@
+-- Время до перехода на фиксированную ширину хранилось как RFC3339Nano в UTC
+-- (2026-01-02T03:04:05.5Z, без дробной части — 2026-01-02T03:04:05Z). Такие строки
+-- неверно сравниваются с новыми (2026-01-02T03:04:05.500000000Z) в фильтрах,
+-- курсорах и таймаутах, поэтому дробная часть дополняется нулями до 9 знаков.
+UPDATE devices
+SET created_at = substr(created_at, 1, 19) || '.' || substr(CASE WHEN length(created_at) > 20 THEN substr(created_at, 21, length(created_at) - 21) ELSE '' END || '000000000', 1, 9) || 'Z'
+WHERE length(created_at) <> 30 AND created_at LIKE '%Z';
+
+UPDATE device_state
+SET updated_at = substr(updated_at, 1, 19) || '.' || substr(CASE WHEN length(updated_at) > 20 THEN substr(updated_at, 21, length(updated_at) - 21) ELSE '' END || '000000000', 1, 9) || 'Z'
+WHERE length(updated_at) <> 30 AND updated_at LIKE '%Z';
+
+UPDATE commands
+SET created_at = substr(created_at, 1, 19) || '.' || substr(CASE WHEN length(created_at) > 20 THEN substr(created_at, 21, length(created_at) - 21) ELSE '' END || '000000000', 1, 9) || 'Z'
+WHERE length(created_at) <> 30 AND created_at LIKE '%Z';
+
+UPDATE commands
+SET acked_at = substr(acked_at, 1, 19) || '.' || substr(CASE WHEN length(acked_at) > 20 THEN substr(acked_at, 21, length(acked_at) - 21) ELSE '' END || '000000000', 1, 9) || 'Z'
+WHERE length(acked_at) <> 30 AND acked_at LIKE '%Z';
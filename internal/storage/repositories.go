@@ -8,7 +8,29 @@ type Device struct {
 	Type         string
 	MQTTDeviceID string
 	Capabilities string // JSON string
+	HomeID       string
+	RoomID       string // пусто — комната не назначена
 	CreatedAt    time.Time
+
+	Online     bool
+	LastSeenAt *time.Time // nil — устройство ещё ничего не присылало
+}
+
+// DefaultHomeID — дом, в который попадают устройства без явного homeId.
+const DefaultHomeID = "1"
+
+type Home struct {
+	ID         string
+	Name       string
+	MQTTHomeID string
+	CreatedAt  time.Time
+}
+
+type Room struct {
+	ID        string
+	HomeID    string
+	Name      string
+	CreatedAt time.Time
 }
 
 type DeviceState struct {
@@ -17,6 +39,14 @@ type DeviceState struct {
 	UpdatedAt time.Time
 }
 
+// Статусы команд (commands.status)
+const (
+	CommandPending = "pending"
+	CommandAcked   = "acked"
+	CommandFailed  = "failed"
+	CommandTimeout = "timeout"
+)
+
 type Command struct {
 	ID         string
 	DeviceID   string
@@ -26,4 +56,163 @@ type Command struct {
 	Error      string
 	CreatedAt  time.Time
 	AckedAt    *time.Time
+
+	Source         string // api | ws | rule:<id> | scene:<id> | schedule:<id>
+	CorrelationID  string
+	GroupCommandID string // пусто — команда не из групповой
+}
+
+// Group — статическая группа устройств.
+type Group struct {
+	ID        string
+	Name      string
+	DeviceIDs []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type GroupCommand struct {
+	ID           string
+	GroupID      string
+	Action       string
+	ParamsJSON   string
+	RejectedJSON string // [{"deviceId":...,"error":...}]
+	CreatedAt    time.Time
+}
+
+// Scene — сохранённый набор команд устройствам.
+type Scene struct {
+	ID        string
+	Name      string
+	StepsJSON string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Статусы запуска сцены (scene_runs.status)
+const (
+	SceneRunRunning     = "running"
+	SceneRunCompleted   = "completed"   // все шаги отправлены
+	SceneRunCancelled   = "cancelled"   // перезапуск или удаление сцены
+	SceneRunInterrupted = "interrupted" // процесс остановился до конца запуска
+)
+
+type SceneRun struct {
+	ID         string
+	SceneID    string
+	Source     string
+	Status     string
+	StepsJSON  string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+}
+
+// Результат последнего срабатывания расписания (schedules.last_status)
+const (
+	ScheduleRunOK     = "ok"
+	ScheduleRunError  = "error"
+	ScheduleRunMissed = "missed" // процесс не работал в момент срабатывания
+)
+
+type Schedule struct {
+	ID          string
+	Name        string
+	Enabled     bool
+	Timezone    string
+	TriggerJSON string
+	TargetJSON  string
+	NextRunAt   *time.Time // nil — выключено или срабатываний не предвидится
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+
+	LastRunAt      *time.Time
+	LastStatus     string
+	LastError      string
+	LastCommandID  string
+	LastSceneRunID string
+}
+
+type Webhook struct {
+	ID             string
+	URL            string
+	EventTypesJSON string // фильтр типов событий; [] — все
+	Secret         string
+	Enabled        bool
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Статусы доставки вебхука (webhook_deliveries.status)
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead" // попытки исчерпаны
+)
+
+type WebhookDelivery struct {
+	ID             string
+	WebhookID      string
+	EventType      string
+	PayloadJSON    string
+	Status         string
+	Attempts       int
+	NextAttemptAt  *time.Time // nil — доставка завершена
+	LastStatusCode int        // 0 — ответа не было (сеть, таймаут)
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type Rule struct {
+	ID             string
+	Name           string
+	Enabled        bool
+	TriggerJSON    string
+	ConditionsJSON string
+	ActionsJSON    string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type APIKey struct {
+	ID         string
+	Name       string
+	KeyHash    string
+	ScopesJSON string
+	CreatedAt  time.Time
+	RevokedAt  *time.Time
+}
+
+type AuditEntry struct {
+	ID        int64
+	At        time.Time
+	Actor     string
+	RequestID string
+	Method    string
+	Route     string
+	Resource  string
+	Status    int
+	Outcome   string
+}
+
+// AuditFilter — фильтры выборки журнала; нулевые поля не ограничивают.
+type AuditFilter struct {
+	From     time.Time
+	To       time.Time
+	Actor    string
+	BeforeID int64 // курсор: записи с id < BeforeID
+	Limit    int
+}
+
+// IdempotencyRecord — сохранённый ответ на запрос с Idempotency-Key.
+// StatusCode == 0 — запрос ещё выполняется.
+type IdempotencyRecord struct {
+	Principal   string
+	Key         string
+	RequestHash string
+	StatusCode  int
+	HeadersJSON string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
 }
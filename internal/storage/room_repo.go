@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+)
+
+type RoomRepo struct{ db queryer }
+
+func NewRoomRepo(db *sql.DB) *RoomRepo { return &RoomRepo{db: instrument(db)} }
+
+const roomColumns = `id, home_id, name, created_at`
+
+// Create добавляет комнату; имя уникально в пределах дома (ErrConflict).
+func (r *RoomRepo) Create(ctx context.Context, rm Room) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO rooms(id, home_id, name, created_at)
+		VALUES(?, ?, ?, ?)
+	`, rm.ID, rm.HomeID, rm.Name, formatTime(rm.CreatedAt))
+	return wrapConflict(err)
+}
+
+func (r *RoomRepo) Get(ctx context.Context, id string) (Room, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+roomColumns+` FROM rooms WHERE id = ?`, id)
+	return scanRoom(row)
+}
+
+func (r *RoomRepo) ListByHome(ctx context.Context, homeID string) ([]Room, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+roomColumns+` FROM rooms WHERE home_id = ? ORDER BY name, id
+	`, homeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Room
+	for rows.Next() {
+		rm, err := scanRoom(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rm)
+	}
+	return out, rows.Err()
+}
+
+// Rename меняет имя комнаты; false — комнаты нет.
+func (r *RoomRepo) Rename(ctx context.Context, id, name string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `UPDATE rooms SET name = ? WHERE id = ?`, name, id)
+	if err != nil {
+		return false, wrapConflict(err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Delete удаляет комнату; у её устройств room_id сбрасывается (ON DELETE SET NULL).
+func (r *RoomRepo) Delete(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM rooms WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanRoom(row rowScanner) (Room, error) {
+	var rm Room
+	var created string
+	if err := row.Scan(&rm.ID, &rm.HomeID, &rm.Name, &created); err != nil {
+		return Room{}, err
+	}
+	rm.CreatedAt = parseTime(created)
+	return rm, nil
+}
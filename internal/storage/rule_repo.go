@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+)
+
+type RuleRepo struct{ db queryer }
+
+func NewRuleRepo(db *sql.DB) *RuleRepo { return &RuleRepo{db: instrument(db)} }
+
+const ruleColumns = `id, name, enabled, trigger_json, conditions_json, actions_json, created_at, updated_at`
+
+func (r *RuleRepo) Create(ctx context.Context, ru Rule) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO rules(id, name, enabled, trigger_json, conditions_json, actions_json, created_at, updated_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+	`, ru.ID, ru.Name, ru.Enabled, ru.TriggerJSON, ru.ConditionsJSON, ru.ActionsJSON,
+		formatTime(ru.CreatedAt), formatTime(ru.UpdatedAt))
+	return err
+}
+
+func (r *RuleRepo) Get(ctx context.Context, id string) (Rule, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+ruleColumns+` FROM rules WHERE id = ?`, id)
+	return scanRule(row)
+}
+
+func (r *RuleRepo) List(ctx context.Context) ([]Rule, error) {
+	return r.list(ctx, `SELECT `+ruleColumns+` FROM rules ORDER BY created_at`)
+}
+
+func (r *RuleRepo) ListEnabled(ctx context.Context) ([]Rule, error) {
+	return r.list(ctx, `SELECT `+ruleColumns+` FROM rules WHERE enabled = 1 ORDER BY created_at`)
+}
+
+// Update перезаписывает правило целиком; false — правила нет.
+func (r *RuleRepo) Update(ctx context.Context, ru Rule) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE rules
+		SET name = ?, enabled = ?, trigger_json = ?, conditions_json = ?, actions_json = ?, updated_at = ?
+		WHERE id = ?
+	`, ru.Name, ru.Enabled, ru.TriggerJSON, ru.ConditionsJSON, ru.ActionsJSON, formatTime(ru.UpdatedAt), ru.ID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *RuleRepo) Delete(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM rules WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *RuleRepo) list(ctx context.Context, query string) ([]Rule, error) {
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Rule
+	for rows.Next() {
+		ru, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ru)
+	}
+	return out, rows.Err()
+}
+
+func scanRule(row rowScanner) (Rule, error) {
+	var ru Rule
+	var created, updated string
+	if err := row.Scan(&ru.ID, &ru.Name, &ru.Enabled, &ru.TriggerJSON, &ru.ConditionsJSON, &ru.ActionsJSON, &created, &updated); err != nil {
+		return Rule{}, err
+	}
+	ru.CreatedAt = parseTime(created)
+	ru.UpdatedAt = parseTime(updated)
+	return ru, nil
+}
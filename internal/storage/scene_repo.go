@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+)
+
+type SceneRepo struct{ db queryer }
+
+func NewSceneRepo(db *sql.DB) *SceneRepo { return &SceneRepo{db: instrument(db)} }
+
+const sceneColumns = `id, name, steps_json, created_at, updated_at`
+
+// Create сохраняет сцену. Занятое имя — ErrConflict.
+func (r *SceneRepo) Create(ctx context.Context, s Scene) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO scenes(id, name, steps_json, created_at, updated_at)
+		VALUES(?, ?, ?, ?, ?)
+	`, s.ID, s.Name, s.StepsJSON, formatTime(s.CreatedAt), formatTime(s.UpdatedAt))
+	return wrapConflict(err)
+}
+
+func (r *SceneRepo) Get(ctx context.Context, id string) (Scene, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+sceneColumns+` FROM scenes WHERE id = ?`, id)
+	return scanScene(row)
+}
+
+func (r *SceneRepo) List(ctx context.Context) ([]Scene, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+sceneColumns+` FROM scenes ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Scene
+	for rows.Next() {
+		s, err := scanScene(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// Update перезаписывает сцену целиком; false — сцены нет.
+func (r *SceneRepo) Update(ctx context.Context, s Scene) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE scenes SET name = ?, steps_json = ?, updated_at = ? WHERE id = ?
+	`, s.Name, s.StepsJSON, formatTime(s.UpdatedAt), s.ID)
+	if err != nil {
+		return false, wrapConflict(err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *SceneRepo) Delete(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM scenes WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanScene(row rowScanner) (Scene, error) {
+	var s Scene
+	var created, updated string
+	if err := row.Scan(&s.ID, &s.Name, &s.StepsJSON, &created, &updated); err != nil {
+		return Scene{}, err
+	}
+	s.CreatedAt = parseTime(created)
+	s.UpdatedAt = parseTime(updated)
+	return s, nil
+}
+
+type SceneRunRepo struct{ db queryer }
+
+func NewSceneRunRepo(db *sql.DB) *SceneRunRepo { return &SceneRunRepo{db: instrument(db)} }
+
+const sceneRunColumns = `id, scene_id, source, status, steps_json, started_at, finished_at`
+
+func (r *SceneRunRepo) Create(ctx context.Context, run SceneRun) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO scene_runs(id, scene_id, source, status, steps_json, started_at, finished_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?)
+	`, run.ID, run.SceneID, run.Source, run.Status, run.StepsJSON, formatTime(run.StartedAt), nullTime(run.FinishedAt))
+	return err
+}
+
+func (r *SceneRunRepo) Get(ctx context.Context, id string) (SceneRun, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+sceneRunColumns+` FROM scene_runs WHERE id = ?`, id)
+	return scanSceneRun(row)
+}
+
+// ListByScene возвращает последние запуски сцены, новые первыми.
+func (r *SceneRunRepo) ListByScene(ctx context.Context, sceneID string, limit int) ([]SceneRun, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+sceneRunColumns+` FROM scene_runs
+		WHERE scene_id = ?
+		ORDER BY started_at DESC, id DESC
+		LIMIT ?
+	`, sceneID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []SceneRun
+	for rows.Next() {
+		run, err := scanSceneRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, run)
+	}
+	return out, rows.Err()
+}
+
+// Update сохраняет прогресс запуска (статус, шаги, время окончания).
+func (r *SceneRunRepo) Update(ctx context.Context, run SceneRun) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE scene_runs SET status = ?, steps_json = ?, finished_at = ? WHERE id = ?
+	`, run.Status, run.StepsJSON, nullTime(run.FinishedAt), run.ID)
+	return err
+}
+
+// ListRunning возвращает незавершённые запуски (после рестарта — прерванные).
+func (r *SceneRunRepo) ListRunning(ctx context.Context) ([]SceneRun, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+sceneRunColumns+` FROM scene_runs WHERE status = ? ORDER BY started_at
+	`, SceneRunRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []SceneRun
+	for rows.Next() {
+		run, err := scanSceneRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, run)
+	}
+	return out, rows.Err()
+}
+
+func scanSceneRun(row rowScanner) (SceneRun, error) {
+	var run SceneRun
+	var started string
+	var finished sql.NullString
+	if err := row.Scan(&run.ID, &run.SceneID, &run.Source, &run.Status, &run.StepsJSON, &started, &finished); err != nil {
+		return SceneRun{}, err
+	}
+	run.StartedAt = parseTime(started)
+	run.FinishedAt = scanNullTime(finished)
+	return run, nil
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type ScheduleRepo struct{ db queryer }
+
+func NewScheduleRepo(db *sql.DB) *ScheduleRepo { return &ScheduleRepo{db: instrument(db)} }
+
+const scheduleColumns = `id, name, enabled, timezone, trigger_json, target_json, next_run_at, created_at, updated_at,
+	last_run_at, last_status, last_error, last_command_id, last_scene_run_id`
+
+func (r *ScheduleRepo) Create(ctx context.Context, s Schedule) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO schedules(id, name, enabled, timezone, trigger_json, target_json, next_run_at, created_at, updated_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, s.ID, s.Name, s.Enabled, s.Timezone, s.TriggerJSON, s.TargetJSON, nullTime(s.NextRunAt),
+		formatTime(s.CreatedAt), formatTime(s.UpdatedAt))
+	return err
+}
+
+func (r *ScheduleRepo) Get(ctx context.Context, id string) (Schedule, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE id = ?`, id)
+	return scanSchedule(row)
+}
+
+func (r *ScheduleRepo) List(ctx context.Context) ([]Schedule, error) {
+	return r.list(ctx, `SELECT `+scheduleColumns+` FROM schedules ORDER BY created_at, id`)
+}
+
+// ListDue возвращает включённые расписания, срок которых наступил к now.
+func (r *ScheduleRepo) ListDue(ctx context.Context, now time.Time) ([]Schedule, error) {
+	return r.list(ctx, `
+		SELECT `+scheduleColumns+` FROM schedules
+		WHERE enabled = 1 AND next_run_at IS NOT NULL AND next_run_at <= ?
+		ORDER BY next_run_at
+	`, formatTime(now))
+}
+
+// Update перезаписывает расписание (кроме результатов запусков); false — расписания нет.
+func (r *ScheduleRepo) Update(ctx context.Context, s Schedule) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE schedules
+		SET name = ?, enabled = ?, timezone = ?, trigger_json = ?, target_json = ?, next_run_at = ?, updated_at = ?
+		WHERE id = ?
+	`, s.Name, s.Enabled, s.Timezone, s.TriggerJSON, s.TargetJSON, nullTime(s.NextRunAt), formatTime(s.UpdatedAt), s.ID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *ScheduleRepo) Delete(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM schedules WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Claim забирает срабатывание due: переносит next_run_at на next, только если
+// он всё ещё равен due (CAS). false — срабатывание уже забрано или расписание
+// изменили/выключили.
+func (r *ScheduleRepo) Claim(ctx context.Context, id string, due time.Time, next *time.Time, now time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE schedules SET next_run_at = ?, last_run_at = ?
+		WHERE id = ? AND enabled = 1 AND next_run_at = ?
+	`, nullTime(next), formatTime(now), id, formatTime(due))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SetResult сохраняет итог последнего срабатывания.
+func (r *ScheduleRepo) SetResult(ctx context.Context, s Schedule) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE schedules SET last_status = ?, last_error = ?, last_command_id = ?, last_scene_run_id = ?
+		WHERE id = ?
+	`, s.LastStatus, s.LastError, s.LastCommandID, s.LastSceneRunID, s.ID)
+	return err
+}
+
+func (r *ScheduleRepo) list(ctx context.Context, query string, args ...any) ([]Schedule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Schedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func scanSchedule(row rowScanner) (Schedule, error) {
+	var s Schedule
+	var created, updated string
+	var next, last sql.NullString
+	if err := row.Scan(&s.ID, &s.Name, &s.Enabled, &s.Timezone, &s.TriggerJSON, &s.TargetJSON, &next, &created, &updated,
+		&last, &s.LastStatus, &s.LastError, &s.LastCommandID, &s.LastSceneRunID); err != nil {
+		return Schedule{}, err
+	}
+	s.CreatedAt = parseTime(created)
+	s.UpdatedAt = parseTime(updated)
+	s.NextRunAt = scanNullTime(next)
+	s.LastRunAt = scanNullTime(last)
+	return s, nil
+}
@@ -0,0 +1,102 @@
+package storage_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+	"github.com/ArthurGuatsaev/smarthome/internal/testutil"
+)
+
+// newScheduleRepo создаёт расписание s1 со сроком due.
+func newScheduleRepo(t *testing.T, enabled bool, due time.Time) *storage.ScheduleRepo {
+	t.Helper()
+	repo := storage.NewScheduleRepo(testutil.NewDB(t).DB)
+	s := storage.Schedule{
+		ID:          "s1",
+		Name:        "daily",
+		Enabled:     enabled,
+		Timezone:    "UTC",
+		TriggerJSON: `{"type":"cron","cron":"0 7 * * *"}`,
+		TargetJSON:  `{"sceneId":"morning"}`,
+		NextRunAt:   &due,
+		CreatedAt:   due.Add(-time.Hour),
+		UpdatedAt:   due.Add(-time.Hour),
+	}
+	if err := repo.Create(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestScheduleClaim(t *testing.T) {
+	ctx := context.Background()
+	due := time.Date(2026, 10, 18, 7, 0, 0, 0, time.UTC)
+	next := due.AddDate(0, 0, 1)
+	now := due.Add(time.Second)
+
+	t.Run("second claim of the same run", func(t *testing.T) {
+		repo := newScheduleRepo(t, true, due)
+		ok, err := repo.Claim(ctx, "s1", due, &next, now)
+		if err != nil || !ok {
+			t.Fatalf("first claim = %v, %v; want true", ok, err)
+		}
+		ok, err = repo.Claim(ctx, "s1", due, &next, now)
+		if err != nil || ok {
+			t.Fatalf("second claim = %v, %v; want false", ok, err)
+		}
+
+		s, err := repo.Get(ctx, "s1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.NextRunAt == nil || !s.NextRunAt.Equal(next) {
+			t.Fatalf("next_run_at = %v, want %v", s.NextRunAt, next)
+		}
+		if s.LastRunAt == nil || !s.LastRunAt.Equal(now) {
+			t.Fatalf("last_run_at = %v, want %v", s.LastRunAt, now)
+		}
+		// следующее срабатывание забирается как обычно
+		if ok, err := repo.Claim(ctx, "s1", next, nil, next); err != nil || !ok {
+			t.Fatalf("next run claim = %v, %v; want true", ok, err)
+		}
+	})
+
+	t.Run("concurrent claims", func(t *testing.T) {
+		repo := newScheduleRepo(t, true, due)
+		var wins atomic.Int32
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Go(func() {
+				ok, err := repo.Claim(ctx, "s1", due, &next, now)
+				if err != nil {
+					t.Error(err)
+				}
+				if ok {
+					wins.Add(1)
+				}
+			})
+		}
+		wg.Wait()
+		if n := wins.Load(); n != 1 {
+			t.Fatalf("%d claims succeeded, want 1", n)
+		}
+	})
+
+	t.Run("stale due", func(t *testing.T) {
+		repo := newScheduleRepo(t, true, due)
+		if ok, err := repo.Claim(ctx, "s1", due.Add(-time.Minute), &next, now); err != nil || ok {
+			t.Fatalf("claim = %v, %v; want false", ok, err)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		repo := newScheduleRepo(t, false, due)
+		if ok, err := repo.Claim(ctx, "s1", due, &next, now); err != nil || ok {
+			t.Fatalf("claim = %v, %v; want false", ok, err)
+		}
+	})
+}
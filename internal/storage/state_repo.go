@@ -3,12 +3,11 @@ package storage
 import (
 	"context"
 	"database/sql"
-	"time"
 )
 
-type StateRepo struct{ db *sql.DB }
+type StateRepo struct{ db queryer }
 
-func NewStateRepo(db *sql.DB) *StateRepo { return &StateRepo{db: db} }
+func NewStateRepo(db *sql.DB) *StateRepo { return &StateRepo{db: instrument(db)} }
 
 func (r *StateRepo) Upsert(ctx context.Context, s DeviceState) error {
 	_, err := r.db.ExecContext(ctx, `
@@ -17,7 +16,7 @@ func (r *StateRepo) Upsert(ctx context.Context, s DeviceState) error {
 		ON CONFLICT(device_id) DO UPDATE SET
 		  state_json = excluded.state_json,
 		  updated_at = excluded.updated_at
-	`, s.DeviceID, s.StateJSON, s.UpdatedAt.UTC().Format(time.RFC3339Nano))
+	`, s.DeviceID, s.StateJSON, formatTime(s.UpdatedAt))
 	return err
 }
 
@@ -33,7 +32,6 @@ func (r *StateRepo) Get(ctx context.Context, deviceID string) (DeviceState, erro
 	if err := row.Scan(&s.DeviceID, &s.StateJSON, &updated); err != nil {
 		return DeviceState{}, err
 	}
-	t, _ := time.Parse(time.RFC3339Nano, updated)
-	s.UpdatedAt = t
+	s.UpdatedAt = parseTime(updated)
 	return s, nil
 }
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Дробная часть фиксированной ширины: строки сравниваются и сортируются
+// в SQLite так же, как время (RFC3339Nano обрезает нули и ломает порядок).
+const timeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
+
+// scanNullTime/nullTime — для nullable-колонок времени (NULL <-> nil).
+func scanNullTime(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t := parseTime(s.String)
+	return &t
+}
+
+func nullTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTime(*t), Valid: true}
+}
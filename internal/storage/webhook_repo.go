@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type WebhookRepo struct{ db queryer }
+
+func NewWebhookRepo(db *sql.DB) *WebhookRepo { return &WebhookRepo{db: instrument(db)} }
+
+const webhookColumns = `id, url, event_types_json, secret, enabled, created_at, updated_at`
+
+func (r *WebhookRepo) Create(ctx context.Context, wh Webhook) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO webhooks(id, url, event_types_json, secret, enabled, created_at, updated_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?)
+	`, wh.ID, wh.URL, wh.EventTypesJSON, wh.Secret, wh.Enabled, formatTime(wh.CreatedAt), formatTime(wh.UpdatedAt))
+	return err
+}
+
+func (r *WebhookRepo) Get(ctx context.Context, id string) (Webhook, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, id)
+	return scanWebhook(row)
+}
+
+func (r *WebhookRepo) List(ctx context.Context) ([]Webhook, error) {
+	return r.list(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY created_at, id`)
+}
+
+func (r *WebhookRepo) ListEnabled(ctx context.Context) ([]Webhook, error) {
+	return r.list(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE enabled = 1 ORDER BY created_at, id`)
+}
+
+// Update перезаписывает подписку; false — её нет.
+func (r *WebhookRepo) Update(ctx context.Context, wh Webhook) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE webhooks SET url = ?, event_types_json = ?, secret = ?, enabled = ?, updated_at = ?
+		WHERE id = ?
+	`, wh.URL, wh.EventTypesJSON, wh.Secret, wh.Enabled, formatTime(wh.UpdatedAt), wh.ID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Delete удаляет подписку вместе с журналом доставок.
+func (r *WebhookRepo) Delete(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *WebhookRepo) list(ctx context.Context, query string) ([]Webhook, error) {
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Webhook
+	for rows.Next() {
+		wh, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, wh)
+	}
+	return out, rows.Err()
+}
+
+func scanWebhook(row rowScanner) (Webhook, error) {
+	var wh Webhook
+	var created, updated string
+	if err := row.Scan(&wh.ID, &wh.URL, &wh.EventTypesJSON, &wh.Secret, &wh.Enabled, &created, &updated); err != nil {
+		return Webhook{}, err
+	}
+	wh.CreatedAt = parseTime(created)
+	wh.UpdatedAt = parseTime(updated)
+	return wh, nil
+}
+
+type WebhookDeliveryRepo struct{ db queryer }
+
+func NewWebhookDeliveryRepo(db *sql.DB) *WebhookDeliveryRepo {
+	return &WebhookDeliveryRepo{db: instrument(db)}
+}
+
+const deliveryColumns = `id, webhook_id, event_type, payload_json, status, attempts, next_attempt_at,
+	last_status_code, last_error, created_at, updated_at`
+
+func (r *WebhookDeliveryRepo) Create(ctx context.Context, d WebhookDelivery) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries(id, webhook_id, event_type, payload_json, status, attempts, next_attempt_at,
+			last_status_code, last_error, created_at, updated_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, d.ID, d.WebhookID, d.EventType, d.PayloadJSON, d.Status, d.Attempts, nullTime(d.NextAttemptAt),
+		d.LastStatusCode, d.LastError, formatTime(d.CreatedAt), formatTime(d.UpdatedAt))
+	return err
+}
+
+func (r *WebhookDeliveryRepo) Get(ctx context.Context, id string) (WebhookDelivery, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = ?`, id)
+	return scanDelivery(row)
+}
+
+// ListDue возвращает pending-доставки включённых подписок, чья попытка наступила.
+func (r *WebhookDeliveryRepo) ListDue(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		  AND webhook_id IN (SELECT id FROM webhooks WHERE enabled = 1)
+		ORDER BY next_attempt_at
+		LIMIT ?
+	`, DeliveryPending, formatTime(now), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// Claim забирает попытку доставки: переносит next_attempt_at на lease, если
+// он всё ещё равен due (CAS). Если процесс упадёт посреди попытки, доставка
+// повторится после lease.
+func (r *WebhookDeliveryRepo) Claim(ctx context.Context, id string, due, lease time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id = ? AND status = ? AND next_attempt_at = ?
+	`, formatTime(lease), id, DeliveryPending, formatTime(due))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SetAttempt сохраняет итог попытки: статус, счётчик, следующую попытку (nil — больше не будет).
+func (r *WebhookDeliveryRepo) SetAttempt(ctx context.Context, d WebhookDelivery) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, updated_at = ?
+		WHERE id = ?
+	`, d.Status, d.Attempts, nullTime(d.NextAttemptAt), d.LastStatusCode, d.LastError, formatTime(d.UpdatedAt), d.ID)
+	return err
+}
+
+// Requeue возвращает доставку из dead-letter в очередь со сброшенным счётчиком;
+// false — доставки нет или она не dead.
+func (r *WebhookDeliveryRepo) Requeue(ctx context.Context, id string, now time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ?, updated_at = ?
+		WHERE id = ? AND status = ?
+	`, DeliveryPending, formatTime(now), formatTime(now), id, DeliveryDead)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteDeliveredBefore чистит журнал успешных доставок; dead остаются до ручного разбора.
+func (r *WebhookDeliveryRepo) DeleteDeliveredBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM webhook_deliveries WHERE status = ? AND updated_at < ?
+	`, DeliveryDelivered, formatTime(before))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeliveryListQuery — фильтры журнала доставок; нулевые поля не ограничивают.
+type DeliveryListQuery struct {
+	WebhookID string // пусто — все подписки
+	Statuses  []string
+	Cursor    string // NextCursor предыдущей страницы
+	Limit     int
+}
+
+type DeliveryPage struct {
+	Items      []WebhookDelivery
+	NextCursor string // пусто — больше страниц нет
+}
+
+// deliveriesSort — единственный порядок журнала доставок (новые первыми).
+const deliveriesSort = "-createdAt"
+
+// List возвращает доставки от новых к старым, keyset-пагинация по (created_at, id).
+func (r *WebhookDeliveryRepo) List(ctx context.Context, q DeliveryListQuery) (DeliveryPage, error) {
+	where := []string{"1 = 1"}
+	var args []any
+	if q.WebhookID != "" {
+		where = append(where, "webhook_id = ?")
+		args = append(args, q.WebhookID)
+	}
+	if len(q.Statuses) > 0 {
+		where = append(where, "status IN (?"+strings.Repeat(", ?", len(q.Statuses)-1)+")")
+		for _, st := range q.Statuses {
+			args = append(args, st)
+		}
+	}
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor, deliveriesSort)
+		if err != nil {
+			return DeliveryPage{}, err
+		}
+		where = append(where, "(created_at < ? OR (created_at = ? AND id < ?))")
+		args = append(args, c.Value, c.Value, c.ID)
+	}
+	// +1 запись, чтобы узнать, есть ли следующая страница
+	args = append(args, q.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return DeliveryPage{}, err
+	}
+	defer rows.Close()
+
+	var out []WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return DeliveryPage{}, err
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return DeliveryPage{}, err
+	}
+
+	page := DeliveryPage{Items: out}
+	if len(out) > q.Limit {
+		page.Items = out[:q.Limit]
+		last := page.Items[q.Limit-1]
+		page.NextCursor = encodeCursor(pageCursor{Sort: deliveriesSort, Value: formatTime(last.CreatedAt), ID: last.ID})
+	}
+	return page, nil
+}
+
+func scanDelivery(row rowScanner) (WebhookDelivery, error) {
+	var d WebhookDelivery
+	var next sql.NullString
+	var created, updated string
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.PayloadJSON, &d.Status, &d.Attempts, &next,
+		&d.LastStatusCode, &d.LastError, &created, &updated); err != nil {
+		return WebhookDelivery{}, err
+	}
+	d.NextAttemptAt = scanNullTime(next)
+	d.CreatedAt = parseTime(created)
+	d.UpdatedAt = parseTime(updated)
+	return d, nil
+}
@@ -0,0 +1,50 @@
+// Package testutil — общие фикстуры тестов: временная БД, приложение и MQTT-брокер.
+package testutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+// NewDB открывает мигрированную БД во временном каталоге теста
+// с домом по умолчанию (MQTT homeId — HomeID).
+func NewDB(t testing.TB) *storage.DB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := storage.Open(ctx, t.TempDir()+"/test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := storage.Migrate(ctx, db.DB); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.NewHomeRepo(db.DB).EnsureDefault(ctx, HomeID, time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+const (
+	// HomeID — homeId в MQTT-топиках тестового приложения.
+	HomeID = "1"
+	// AdminKey — bootstrap-ключ администратора тестового приложения.
+	AdminKey = "test-admin-key"
+)
+
+// NewApp — приложение поверх NewDB; pub == nil — команды никуда не публикуются.
+func NewApp(t testing.TB, pub app.Publisher) *app.App {
+	t.Helper()
+	if pub == nil {
+		pub = nopPublisher{}
+	}
+	return app.New(NewDB(t), pub, AdminKey)
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(context.Context, string, byte, []byte) error { return nil }
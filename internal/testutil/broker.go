@@ -0,0 +1,306 @@
+package testutil
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/mqtt"
+)
+
+// Broker — минимальный MQTT 3.1.1 брокер на loopback для интеграционных тестов:
+// CONNECT, SUBSCRIBE/UNSUBSCRIBE с wildcard-фильтрами, PUBLISH QoS 0/1, PINGREQ.
+// Подписчикам сообщения доставляются с QoS 0; сессии и retained не хранятся.
+type Broker struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	clients map[*brokerConn]struct{}
+	subbed  chan string // фильтры по мере подтверждения подписок
+}
+
+type brokerConn struct {
+	conn net.Conn
+
+	wmu  sync.Mutex
+	subs map[string]struct{} // под Broker.mu
+}
+
+const (
+	pktConnect     = 1
+	pktPublish     = 3
+	pktPuback      = 4
+	pktSubscribe   = 8
+	pktUnsubscribe = 10
+	pktPingreq     = 12
+	pktDisconnect  = 14
+)
+
+// NewBroker запускает брокер на 127.0.0.1; останавливается в Cleanup теста.
+func NewBroker(t testing.TB) *Broker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Broker{ln: ln, clients: map[*brokerConn]struct{}{}, subbed: make(chan string, 64)}
+	go b.accept()
+	t.Cleanup(b.close)
+	return b
+}
+
+// URL — адрес для mqtt.Config.BrokerURL.
+func (b *Broker) URL() string { return "tcp://" + b.ln.Addr().String() }
+
+// Connect подключает к брокеру клиента приложения и ждёт соединения.
+func (b *Broker) Connect(t testing.TB, clientID string) *mqtt.Client {
+	t.Helper()
+	c := mqtt.New(mqtt.Config{BrokerURL: b.URL(), ClientID: clientID})
+	t.Cleanup(c.Close)
+	Eventually(t, "mqtt client "+clientID+" to connect", c.IsConnected)
+	return c
+}
+
+// WaitSubscribed ждёт, пока какой-либо клиент подпишется на filter.
+func (b *Broker) WaitSubscribed(t testing.TB, filter string) {
+	t.Helper()
+	deadline := time.After(WaitTimeout)
+	for {
+		select {
+		case f := <-b.subbed:
+			if f == filter {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("no subscription to %q", filter)
+		}
+	}
+}
+
+// route раздаёт опубликованное сообщение подписчикам.
+func (b *Broker) route(topic string, payload []byte) {
+	b.mu.Lock()
+	var targets []*brokerConn
+	for c := range b.clients {
+		for f := range c.subs {
+			if topicMatch(f, topic) {
+				targets = append(targets, c)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	for _, c := range targets {
+		body := appendString(nil, topic)
+		body = append(body, payload...)
+		_ = c.write(pktPublish<<4, body)
+	}
+}
+
+func (b *Broker) close() {
+	b.ln.Close()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.clients {
+		c.conn.Close()
+	}
+}
+
+func (b *Broker) accept() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &brokerConn{conn: conn, subs: map[string]struct{}{}}
+		b.mu.Lock()
+		b.clients[c] = struct{}{}
+		b.mu.Unlock()
+		go b.serve(c)
+	}
+}
+
+func (b *Broker) serve(c *brokerConn) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, c)
+		b.mu.Unlock()
+		c.conn.Close()
+	}()
+
+	r := bufio.NewReader(c.conn)
+	for {
+		header, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch header >> 4 {
+		case pktConnect:
+			err = c.write(0x20, []byte{0, 0}) // CONNACK: accepted
+		case pktPublish:
+			err = b.onPublish(c, header, body)
+		case pktSubscribe:
+			err = b.onSubscribe(c, body)
+		case pktUnsubscribe:
+			err = b.onUnsubscribe(c, body)
+		case pktPingreq:
+			err = c.write(0xD0, nil)
+		case pktDisconnect:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (b *Broker) onPublish(c *brokerConn, header byte, body []byte) error {
+	topic, rest, err := readString(body)
+	if err != nil {
+		return err
+	}
+	if qos := (header >> 1) & 3; qos > 0 {
+		if len(rest) < 2 {
+			return errors.New("publish: no packet id")
+		}
+		if err := c.write(pktPuback<<4, rest[:2]); err != nil {
+			return err
+		}
+		rest = rest[2:]
+	}
+	b.route(topic, rest)
+	return nil
+}
+
+func (b *Broker) onSubscribe(c *brokerConn, body []byte) error {
+	if len(body) < 2 {
+		return errors.New("subscribe: no packet id")
+	}
+	ack := append([]byte{}, body[:2]...)
+	var filters []string
+	for rest := body[2:]; len(rest) > 0; {
+		f, r, err := readString(rest)
+		if err != nil || len(r) < 1 {
+			return errors.New("subscribe: bad filter")
+		}
+		filters = append(filters, f)
+		ack = append(ack, 0) // granted QoS 0
+		rest = r[1:]
+	}
+
+	b.mu.Lock()
+	for _, f := range filters {
+		c.subs[f] = struct{}{}
+	}
+	b.mu.Unlock()
+	if err := c.write(0x90, ack); err != nil {
+		return err
+	}
+	for _, f := range filters {
+		select {
+		case b.subbed <- f:
+		default:
+		}
+	}
+	return nil
+}
+
+func (b *Broker) onUnsubscribe(c *brokerConn, body []byte) error {
+	if len(body) < 2 {
+		return errors.New("unsubscribe: no packet id")
+	}
+	b.mu.Lock()
+	for rest := body[2:]; len(rest) > 0; {
+		f, r, err := readString(rest)
+		if err != nil {
+			break
+		}
+		delete(c.subs, f)
+		rest = r
+	}
+	b.mu.Unlock()
+	return c.write(0xB0, body[:2])
+}
+
+func (c *brokerConn) write(header byte, body []byte) error {
+	pkt := []byte{header}
+	// remaining length: 7 бит на байт, старший бит — продолжение
+	n := len(body)
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		pkt = append(pkt, d)
+		if n == 0 {
+			break
+		}
+	}
+	pkt = append(pkt, body...)
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.conn.Write(pkt)
+	return err
+}
+
+func readPacket(r *bufio.Reader) (header byte, body []byte, err error) {
+	if header, err = r.ReadByte(); err != nil {
+		return 0, nil, err
+	}
+	n, mul := 0, 1
+	for i := 0; ; i++ {
+		d, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(d&0x7F) * mul
+		if d&0x80 == 0 {
+			break
+		}
+		if mul *= 128; i == 3 {
+			return 0, nil, errors.New("bad remaining length")
+		}
+	}
+	body = make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("short string")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("short string")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// topicMatch сопоставляет топик с фильтром подписки (+ — один уровень, # — остаток).
+func topicMatch(filter, topic string) bool {
+	fs, ts := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, f := range fs {
+		if f == "#" {
+			return true
+		}
+		if i >= len(ts) || (f != "+" && f != ts[i]) {
+			return false
+		}
+	}
+	return len(fs) == len(ts)
+}
@@ -0,0 +1,25 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+)
+
+// WaitTimeout — сколько тесты ждут асинхронный результат.
+const WaitTimeout = 5 * time.Second
+
+// Eventually опрашивает cond, пока он не вернёт true; по истечении
+// WaitTimeout тест падает с сообщением what.
+func Eventually(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	deadline := time.After(WaitTimeout)
+	for !cond() {
+		select {
+		case <-tick.C:
+		case <-deadline:
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
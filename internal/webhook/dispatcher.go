@@ -0,0 +1,293 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/buildinfo"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+)
+
+const (
+	// Буфер подписки на шину: доставки ставятся в очередь в БД, так что он нужен только на всплески
+	eventBuffer = 1024
+	// Как часто проверять очередь, если новых событий нет (повторные попытки)
+	pollInterval = time.Second
+	// Сколько доставок забирать за проход и сколько выполнять одновременно
+	batchSize   = 50
+	concurrency = 4
+	// Экспоненциальная пауза между попытками: 5s, 10s, 20s, ... не больше часа
+	backoffBase = 5 * time.Second
+	backoffMax  = time.Hour
+	// Запас lease сверх таймаута попытки
+	leaseSlack = 5 * time.Second
+	// Сколько тела ответа читать (остальное получатель может не слать)
+	maxResponseBody = 64 << 10
+	pruneInterval   = time.Hour
+)
+
+type Config struct {
+	Timeout      time.Duration // на одну попытку
+	MaxAttempts  int           // после стольких неудач доставка уходит в dead
+	LogRetention time.Duration // сколько хранить delivered-доставки; 0 — бессрочно
+	Client       *http.Client  // nil — http.Client с Timeout
+}
+
+// Dispatcher доставляет события шины подписчикам-вебхукам.
+//
+// Каждое подходящее событие сначала записывается в webhook_deliveries
+// (pending), а доставляется отдельным циклом: так очередь переживает рестарт,
+// а повторные попытки идут с экспоненциальной паузой. Попытку забирает CAS
+// по next_attempt_at (lease), поэтому её не выполнят дважды.
+type Dispatcher struct {
+	app    *app.App
+	cfg    Config
+	client *http.Client
+	wake   chan struct{}
+}
+
+func New(a *app.App, cfg Config) *Dispatcher {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Timeout}
+	}
+	return &Dispatcher{app: a, cfg: cfg, client: client, wake: make(chan struct{}, 1)}
+}
+
+// Run блокируется до отмены ctx.
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.run(ctx, d.app.Events.Subscribe(eventBuffer, nil))
+}
+
+// run обслуживает уже оформленную подписку: события, опубликованные
+// между Subscribe и запуском цикла, не теряются.
+func (d *Dispatcher) run(ctx context.Context, sub *app.Subscription) {
+	defer sub.Close()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	wg.Go(func() { d.deliverLoop(ctx) })
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			d.enqueue(ctx, ev)
+		}
+	}
+}
+
+func (d *Dispatcher) enqueue(ctx context.Context, ev app.Event) {
+	hooks, err := d.app.Webhooks.ListEnabled(ctx)
+	if err != nil {
+		slog.Error("webhooks_load_error", "err", err)
+		return
+	}
+
+	var payload []byte
+	now := time.Now().UTC()
+	queued := false
+	for _, wh := range hooks {
+		var types []string
+		_ = json.Unmarshal([]byte(wh.EventTypesJSON), &types)
+		if !Matches(types, ev.Type) {
+			continue
+		}
+		if payload == nil {
+			payload, _ = json.Marshal(ev)
+		}
+
+		err := d.app.Deliveries.Create(ctx, storage.WebhookDelivery{
+			ID:            newID(),
+			WebhookID:     wh.ID,
+			EventType:     string(ev.Type),
+			PayloadJSON:   string(payload),
+			Status:        storage.DeliveryPending,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+		if err != nil {
+			slog.Error("webhook_enqueue_error", "webhook_id", wh.ID, "event_type", ev.Type, "err", err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (d *Dispatcher) deliverLoop(ctx context.Context) {
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+			d.deliverDue(ctx)
+		case <-poll.C:
+			d.deliverDue(ctx)
+		case <-prune.C:
+			d.prune(ctx)
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	now := time.Now().UTC()
+	due, err := d.app.Deliveries.ListDue(ctx, now, batchSize)
+	if err != nil {
+		slog.Error("webhook_deliveries_load_error", "err", err)
+		return
+	}
+
+	hooks := map[string]storage.Webhook{}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, dl := range due {
+		wh, ok := hooks[dl.WebhookID]
+		if !ok {
+			if wh, err = d.app.Webhooks.Get(ctx, dl.WebhookID); err != nil {
+				// sql.ErrNoRows: подписку только что удалили, доставки уйдут каскадом
+				if !errors.Is(err, sql.ErrNoRows) {
+					slog.Error("webhook_load_error", "webhook_id", dl.WebhookID, "err", err)
+				}
+				continue
+			}
+			hooks[wh.ID] = wh
+		}
+
+		lease := now.Add(d.cfg.Timeout + leaseSlack)
+		claimed, err := d.app.Deliveries.Claim(ctx, dl.ID, *dl.NextAttemptAt, lease)
+		if err != nil {
+			slog.Error("webhook_claim_error", "delivery_id", dl.ID, "err", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			d.attempt(ctx, wh, dl)
+		})
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, wh storage.Webhook, dl storage.WebhookDelivery) {
+	code, err := d.post(ctx, wh, dl)
+	if ctx.Err() != nil {
+		// остановка сервера: попытку не считаем, после рестарта её повторят по истечении lease
+		return
+	}
+
+	now := time.Now().UTC()
+	dl.Attempts++
+	dl.LastStatusCode = code
+	dl.UpdatedAt = now
+
+	switch {
+	case err == nil:
+		dl.Status, dl.NextAttemptAt, dl.LastError = storage.DeliveryDelivered, nil, ""
+	case dl.Attempts >= d.cfg.MaxAttempts:
+		dl.Status, dl.NextAttemptAt, dl.LastError = storage.DeliveryDead, nil, err.Error()
+		slog.Warn("webhook_dead", "webhook_id", wh.ID, "delivery_id", dl.ID, "attempts", dl.Attempts, "err", err)
+	default:
+		next := now.Add(backoff(dl.Attempts))
+		dl.Status, dl.NextAttemptAt, dl.LastError = storage.DeliveryPending, &next, err.Error()
+		slog.Info("webhook_retry", "webhook_id", wh.ID, "delivery_id", dl.ID, "attempts", dl.Attempts, "next", next, "err", err)
+	}
+
+	if err := d.app.Deliveries.SetAttempt(context.WithoutCancel(ctx), dl); err != nil {
+		slog.Error("webhook_attempt_save_error", "delivery_id", dl.ID, "err", err)
+	}
+}
+
+// post отправляет доставку; успех — только ответ 2xx.
+func (d *Dispatcher) post(ctx context.Context, wh storage.Webhook, dl storage.WebhookDelivery) (int, error) {
+	if d.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.cfg.Timeout)
+		defer cancel()
+	}
+
+	body := []byte(dl.PayloadJSON)
+	ts := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "smarthome-webhook/"+buildinfo.Version)
+	req.Header.Set(HeaderEvent, dl.EventType)
+	req.Header.Set(HeaderDelivery, dl.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(wh.Secret, ts, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &statusError{code: resp.StatusCode}
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) prune(ctx context.Context) {
+	if d.cfg.LogRetention <= 0 {
+		return
+	}
+	n, err := d.app.Deliveries.DeleteDeliveredBefore(ctx, time.Now().UTC().Add(-d.cfg.LogRetention))
+	if err != nil {
+		slog.Error("webhook_prune_error", "err", err)
+		return
+	}
+	if n > 0 {
+		slog.Info("webhook_prune", "deleted", n)
+	}
+}
+
+// backoff — пауза после attempts неудачных попыток.
+func backoff(attempts int) time.Duration {
+	d := backoffBase
+	for i := 1; i < attempts && d < backoffMax; i++ {
+		d *= 2
+	}
+	return min(d, backoffMax)
+}
+
+type statusError struct{ code int }
+
+func (e *statusError) Error() string { return "unexpected status " + strconv.Itoa(e.code) }
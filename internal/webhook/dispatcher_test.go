@@ -0,0 +1,236 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+	"github.com/ArthurGuatsaev/smarthome/internal/httpapi"
+	"github.com/ArthurGuatsaev/smarthome/internal/storage"
+	"github.com/ArthurGuatsaev/smarthome/internal/testutil"
+	"github.com/ArthurGuatsaev/smarthome/internal/webhook"
+)
+
+const testSecret = "whsec_0123456789abcdef"
+
+// receivedHook — запрос, который получил тестовый получатель.
+type receivedHook struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver — локальный получатель вебхуков; код ответа меняется на ходу.
+type receiver struct {
+	*httptest.Server
+	status atomic.Int32
+	got    chan receivedHook
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	t.Helper()
+	rc := &receiver{got: make(chan receivedHook, 16)}
+	rc.status.Store(int32(status))
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rc.got <- receivedHook{header: r.Header.Clone(), body: body}
+		w.WriteHeader(int(rc.status.Load()))
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) next(t *testing.T) receivedHook {
+	t.Helper()
+	select {
+	case h := <-rc.got:
+		return h
+	case <-time.After(testutil.WaitTimeout):
+		t.Fatal("webhook was not delivered")
+		return receivedHook{}
+	}
+}
+
+func createWebhook(t *testing.T, a *app.App, url string) storage.Webhook {
+	t.Helper()
+	now := time.Now().UTC()
+	wh := storage.Webhook{
+		ID:             "wh1",
+		URL:            url,
+		EventTypesJSON: `["device.*"]`,
+		Secret:         testSecret,
+		Enabled:        true,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := a.Webhooks.Create(context.Background(), wh); err != nil {
+		t.Fatal(err)
+	}
+	return wh
+}
+
+// waitDelivery ждёт, пока единственная доставка подписки не удовлетворит cond.
+func waitDelivery(t *testing.T, a *app.App, webhookID string, cond func(storage.WebhookDelivery) bool) storage.WebhookDelivery {
+	t.Helper()
+	var dl storage.WebhookDelivery
+	testutil.Eventually(t, "delivery state", func() bool {
+		page, err := a.Deliveries.List(context.Background(), storage.DeliveryListQuery{WebhookID: webhookID, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Items) != 1 || !cond(page.Items[0]) {
+			return false
+		}
+		dl = page.Items[0]
+		return true
+	})
+	return dl
+}
+
+func TestDispatcherRetriesUntilDead(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a := testutil.NewApp(t, nil)
+	rc := newReceiver(t, http.StatusInternalServerError)
+	wh := createWebhook(t, a, rc.URL)
+
+	d := webhook.New(a, webhook.Config{Timeout: 2 * time.Second, MaxAttempts: 2})
+	done := d.Start(ctx)
+	defer func() { cancel(); <-done }()
+
+	a.Events.Publish(app.Event{Type: app.EventDeviceCreated, DeviceID: "dev1"})
+
+	// первая попытка: подпись сверяется получателем через Verify
+	h := rc.next(t)
+	if got := h.header.Get(webhook.HeaderEvent); got != string(app.EventDeviceCreated) {
+		t.Fatalf("%s = %q, want %q", webhook.HeaderEvent, got, app.EventDeviceCreated)
+	}
+	ts, err := strconv.ParseInt(h.header.Get(webhook.HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("bad %s: %v", webhook.HeaderTimestamp, err)
+	}
+	sig := h.header.Get(webhook.HeaderSignature)
+	if !webhook.Verify(testSecret, ts, h.body, sig) {
+		t.Fatalf("signature %q does not verify", sig)
+	}
+	if webhook.Verify("whsec_another-secret-value", ts, h.body, sig) {
+		t.Fatal("signature verifies with a different secret")
+	}
+	var ev app.Event
+	if err := json.Unmarshal(h.body, &ev); err != nil || ev.DeviceID != "dev1" {
+		t.Fatalf("payload = %s (%v)", h.body, err)
+	}
+
+	// 500 — повтор через backoff(1)
+	dl := waitDelivery(t, a, wh.ID, func(dl storage.WebhookDelivery) bool { return dl.Attempts == 1 })
+	if dl.Status != storage.DeliveryPending || dl.LastStatusCode != http.StatusInternalServerError {
+		t.Fatalf("after first attempt: status %s, code %d", dl.Status, dl.LastStatusCode)
+	}
+	if dl.NextAttemptAt == nil {
+		t.Fatal("next attempt is not scheduled")
+	}
+	if wait := dl.NextAttemptAt.Sub(dl.UpdatedAt); wait != webhook.Backoff(1) {
+		t.Fatalf("retry scheduled in %s, want %s", wait, webhook.Backoff(1))
+	}
+	if h.header.Get(webhook.HeaderDelivery) != dl.ID {
+		t.Fatalf("%s = %q, want %q", webhook.HeaderDelivery, h.header.Get(webhook.HeaderDelivery), dl.ID)
+	}
+
+	// не ждём backoff: переносим попытку на сейчас
+	now := time.Now().UTC()
+	dl.NextAttemptAt = &now
+	if err := a.Deliveries.SetAttempt(ctx, dl); err != nil {
+		t.Fatal(err)
+	}
+
+	// вторая попытка — последняя (MaxAttempts = 2): доставка уходит в dead
+	rc.next(t)
+	dl = waitDelivery(t, a, wh.ID, func(dl storage.WebhookDelivery) bool { return dl.Attempts == 2 })
+	if dl.Status != storage.DeliveryDead || dl.NextAttemptAt != nil {
+		t.Fatalf("after last attempt: status %s, next %v", dl.Status, dl.NextAttemptAt)
+	}
+
+	// dead-letter: список и повтор через API
+	api := httptest.NewServer(httpapi.NewServer(a, httpapi.Config{}).Handler())
+	defer api.Close()
+
+	var dead []struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	apiCall(t, api.URL, http.MethodGet, "/api/v1/webhooks/dead-letters", http.StatusOK, &dead)
+	if len(dead) != 1 || dead[0].ID != dl.ID {
+		t.Fatalf("dead letters = %+v, want %s", dead, dl.ID)
+	}
+
+	rc.status.Store(http.StatusNoContent)
+	retryPath := "/api/v1/webhooks/" + wh.ID + "/deliveries/" + dl.ID + "/retry"
+	var retried struct {
+		Status   string `json:"status"`
+		Attempts int    `json:"attempts"`
+	}
+	apiCall(t, api.URL, http.MethodPost, retryPath, http.StatusAccepted, &retried)
+	if retried.Status != storage.DeliveryPending || retried.Attempts != 0 {
+		t.Fatalf("retry response = %+v", retried)
+	}
+
+	rc.next(t)
+	waitDelivery(t, a, wh.ID, func(dl storage.WebhookDelivery) bool { return dl.Status == storage.DeliveryDelivered })
+
+	// повторить можно только dead-доставку
+	apiCall(t, api.URL, http.MethodPost, retryPath, http.StatusConflict, nil)
+	apiCall(t, api.URL, http.MethodPost, "/api/v1/webhooks/other/deliveries/"+dl.ID+"/retry", http.StatusNotFound, nil)
+	apiCall(t, api.URL, http.MethodGet, "/api/v1/webhooks/dead-letters", http.StatusOK, &dead)
+	if len(dead) != 0 {
+		t.Fatalf("dead letters after retry = %+v", dead)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{10, 2560 * time.Second},
+		{11, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := webhook.Backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func apiCall(t *testing.T, base, method, path string, wantStatus int, out any) {
+	t.Helper()
+	req, err := http.NewRequest(method, base+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-API-Key", testutil.AdminKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("%s %s: status %d, want %d: %s", method, path, resp.StatusCode, wantStatus, body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			t.Fatalf("%s %s: %v: %s", method, path, err, body)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package webhook
+
+import "context"
+
+// Backoff открывает внутреннюю паузу между попытками для тестов пакета webhook_test.
+var Backoff = backoff
+
+// Start подписывает диспетчер на шину до возврата и запускает его в фоне:
+// события, опубликованные после Start, гарантированно дойдут до диспетчера.
+// done закрывается после остановки по ctx.
+func (d *Dispatcher) Start(ctx context.Context) (done <-chan struct{}) {
+	sub := d.app.Events.Subscribe(eventBuffer, nil)
+	ch := make(chan struct{})
+	go func() {
+		d.run(ctx, sub)
+		close(ch)
+	}()
+	return ch
+}
@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ArthurGuatsaev/smarthome/internal/app"
+)
+
+// Заголовки запроса доставки
+const (
+	HeaderEvent     = "X-Smarthome-Event"
+	HeaderDelivery  = "X-Smarthome-Delivery" // id доставки: одинаков во всех попытках
+	HeaderTimestamp = "X-Smarthome-Timestamp"
+	HeaderSignature = "X-Smarthome-Signature"
+)
+
+// Короче секрет не принимаем: HMAC с коротким ключом легко подобрать
+const minSecretLen = 16
+
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// Sign подписывает тело: "sha256=" + hex(HMAC-SHA256(secret, "<timestamp>.<body>")).
+// Timestamp в подписи не даёт переиграть старый запрос: получатель сверяет его с часами.
+func Sign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify — проверка подписи на стороне получателя, за постоянное время.
+func Verify(secret string, ts int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature))
+}
+
+func NewSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func ValidateSecret(secret string) error {
+	if len(secret) < minSecretLen {
+		return fmt.Errorf("%w: secret must be at least %d characters", ErrInvalidWebhook, minSecretLen)
+	}
+	return nil
+}
+
+// ValidateURL пропускает только абсолютные http(s)-адреса.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) url", ErrInvalidWebhook)
+	}
+	return nil
+}
+
+// ValidateFilter проверяет фильтр типов событий: точный тип ("command.ack")
+// или все типы группы ("device.*"). Пустой фильтр — все события.
+func ValidateFilter(types []string) error {
+	for _, t := range types {
+		if !slices.ContainsFunc(app.EventTypes, func(et app.EventType) bool { return matchType(t, et) }) {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, t)
+		}
+	}
+	return nil
+}
+
+// Matches сообщает, проходит ли событие типа t через фильтр.
+func Matches(types []string, t app.EventType) bool {
+	if len(types) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(types, func(f string) bool { return matchType(f, t) })
+}
+
+func matchType(filter string, t app.EventType) bool {
+	if prefix, ok := strings.CutSuffix(filter, ".*"); ok {
+		return strings.HasPrefix(string(t), prefix+".")
+	}
+	return filter == string(t)
+}